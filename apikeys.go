@@ -51,6 +51,10 @@ type APIKey struct {
 	Project *Project `json:"project"`
 }
 
+func (k APIKey) String() string {
+	return Stringify(k)
+}
+
 // APIKeyCreateRequest type used to create an api key.
 type APIKeyCreateRequest struct {
 	// Description is any text description of the key. This can be used to
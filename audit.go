@@ -0,0 +1,246 @@
+package packngo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+const headerRequestID = "X-Request-Id"
+
+// AuditRecord is the audit log entry of one mutating request
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor,omitempty"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+
+	// Body is the request body with secrets redacted
+	Body json.RawMessage `json:"body,omitempty"`
+
+	// Status is zero when no response was received
+	Status int `json:"status"`
+
+	// ResourceID is the id of the response body, or else the last ID of
+	// the path
+	ResourceID string `json:"resource_id,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// AuditSink stores AuditRecords
+type AuditSink interface {
+	WriteAudit(AuditRecord) error
+}
+
+// WithAuditSink records every POST, PUT, PATCH and DELETE request sent by
+// Client in sink, attributed to actor. Failures to write a record are logged
+// and do not fail the request.
+func WithAuditSink(sink AuditSink, actor string) ClientOpt {
+	return func(c *Client) error {
+		c.auditSink = sink
+		c.auditActor = actor
+
+		return nil
+	}
+}
+
+// auditing reports whether req must be recorded
+func (c *Client) auditing(req *http.Request) bool {
+	if c.auditSink == nil {
+		return false
+	}
+	switch req.Method {
+	case "POST", "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+// newAuditRecord describes req before it is sent
+func (c *Client) newAuditRecord(req *http.Request) AuditRecord {
+	record := AuditRecord{
+		Time:   time.Now().UTC(),
+		Actor:  c.auditActor,
+		Method: req.Method,
+		Path:   req.URL.Path,
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := ioutil.ReadAll(body)
+			body.Close()
+			record.Body = redactJSON(b)
+		}
+	}
+	return record
+}
+
+// writeAudit completes record with the outcome of the request and writes it
+// to the sink
+func (c *Client) writeAudit(record AuditRecord, resp *http.Response, body []byte, err error) {
+	if resp != nil {
+		record.Status = resp.StatusCode
+		record.RequestID = resp.Header.Get(headerRequestID)
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	resource := new(struct {
+		ID string `json:"id"`
+	})
+	if json.Unmarshal(body, resource) == nil && resource.ID != "" {
+		record.ResourceID = resource.ID
+	} else {
+		for dir := record.Path; dir != "/" && dir != "."; dir = path.Dir(dir) {
+			if ValidateUUID(path.Base(dir)) == nil {
+				record.ResourceID = path.Base(dir)
+				break
+			}
+		}
+	}
+
+	if err := c.auditSink.WriteAudit(record); err != nil {
+		log.Printf("WARNING: failed to write audit record for %s %s: %v", record.Method, record.Path, err)
+	}
+}
+
+// WriterAuditSink writes AuditRecords as JSON lines
+type WriterAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterAuditSink writes to w
+func NewWriterAuditSink(w io.Writer) *WriterAuditSink {
+	return &WriterAuditSink{w: w}
+}
+
+// WriteAudit writes record as one line of JSON
+func (s *WriterAuditSink) WriteAudit(record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// FileAuditSink appends AuditRecords as JSON lines to a file. When a record
+// would grow the file beyond MaxBytes, the file is rotated: name is renamed
+// to name.1, name.1 to name.2 and so on, keeping MaxBackups old files. At
+// least one old file is kept, so rotation never discards the latest records.
+type FileAuditSink struct {
+	MaxBytes   int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	name string
+	f    *os.File
+	size int64
+}
+
+// NewFileAuditSink opens or creates the file name for appending. A maxBytes
+// of zero disables rotation, otherwise maxBackups must be at least 1.
+func NewFileAuditSink(name string, maxBytes int64, maxBackups int) (*FileAuditSink, error) {
+	if maxBytes > 0 && maxBackups < 1 {
+		return nil, fmt.Errorf("audit file %s: rotating at %d bytes requires at least 1 backup, got %d", name, maxBytes, maxBackups)
+	}
+	s := &FileAuditSink{MaxBytes: maxBytes, MaxBackups: maxBackups, name: name}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileAuditSink) open() error {
+	f, err := os.OpenFile(s.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f, s.size = f, info.Size()
+	return nil
+}
+
+// WriteAudit appends record as one line of JSON, rotating the file first when
+// needed
+func (s *FileAuditSink) WriteAudit(record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return fmt.Errorf("audit file %s is closed", s.name)
+	}
+	var rotateErr error
+	if s.MaxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.MaxBytes {
+		// a failed rotation is retried by the next record
+		if rotateErr = s.rotate(); s.f == nil {
+			return rotateErr
+		}
+	}
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return err
+}
+
+// rotate renames the backups and the file and opens a new file. When a rename
+// fails, name is reopened for appending, so that later records are still
+// written.
+func (s *FileAuditSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	s.f = nil
+
+	if err := s.renameBackups(); err != nil {
+		if openErr := s.open(); openErr != nil {
+			return fmt.Errorf("rotating audit file %s: %v, reopening it: %w", s.name, err, openErr)
+		}
+		return fmt.Errorf("rotating audit file %s: %w", s.name, err)
+	}
+	return s.open()
+}
+
+func (s *FileAuditSink) renameBackups() error {
+	backup := func(i int) string { return fmt.Sprintf("%s.%d", s.name, i) }
+	for i := s.MaxBackups - 1; i > 0; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(s.name, backup(1))
+}
+
+// Close closes the file
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
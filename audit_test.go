@@ -0,0 +1,197 @@
+package packngo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithAuditSink(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(headerRequestID, "req-"+r.Method)
+		switch r.Method {
+		case "POST":
+			if strings.HasSuffix(r.URL.Path, "/bad") {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(w, `{"errors": ["hostname is invalid"]}`)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id": "new-device", "hostname": "web"}`)
+		case "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			fmt.Fprint(w, `{"id": "plan"}`)
+		}
+	}))
+	defer ts.Close()
+
+	buf := new(bytes.Buffer)
+	c, err := NewClient(WithAuth("packngo test", "token"), WithBaseURL(ts.URL+"/"), WithAuditSink(NewWriterAuditSink(buf), "ci-bot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device := new(Device)
+	body := map[string]interface{}{"hostname": "web", "root_password": "s3cret", "ports": []interface{}{map[string]interface{}{"token": "t0k3n"}}}
+	if _, err := c.DoRequest("POST", "/projects/p1/devices", body, device); err != nil {
+		t.Fatal(err)
+	}
+	if device.ID != "new-device" {
+		t.Errorf("expected the response to be decoded, got %+v", device)
+	}
+	if _, err := c.DoRequest("GET", "/plans/plan", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Devices.Delete(testPolicyDevice, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DoRequest("POST", "/projects/p1/bad", nil, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	create := records[0]
+	if create.Actor != "ci-bot" || create.Method != "POST" || create.Path != "/projects/p1/devices" || create.Status != 201 ||
+		create.ResourceID != "new-device" || create.RequestID != "req-POST" || create.Time.IsZero() {
+		t.Errorf("unexpected create record %+v", create)
+	}
+	if strings.Contains(string(create.Body), "s3cret") || strings.Contains(string(create.Body), "t0k3n") || !strings.Contains(string(create.Body), `"hostname":"web"`) {
+		t.Errorf("unexpected redacted body %s", create.Body)
+	}
+
+	del := records[1]
+	if del.Method != "DELETE" || del.Status != 204 || del.ResourceID != testPolicyDevice || del.Error != "" {
+		t.Errorf("unexpected delete record %+v", del)
+	}
+
+	bad := records[2]
+	if bad.Status != 422 || !strings.Contains(bad.Error, "hostname is invalid") {
+		t.Errorf("unexpected failed record %+v", bad)
+	}
+}
+
+func TestFileAuditSink_Rotate(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	s, err := NewFileAuditSink(name, 150, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := s.WriteAudit(AuditRecord{Method: "POST", Path: fmt.Sprintf("/projects/p%d/devices", i), Status: 201}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for file, path := range map[string]string{name: "/projects/p4/devices", name + ".1": "/projects/p3/devices", name + ".2": "/projects/p2/devices"} {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r AuditRecord
+		if err := json.Unmarshal(b, &r); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if r.Path != path {
+			t.Errorf("%s: expected %s, got %s", file, path, r.Path)
+		}
+	}
+	if _, err := os.Stat(name + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 backups, got %v", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteAudit(AuditRecord{}); err == nil {
+		t.Error("expected an error after Close")
+	}
+}
+
+func TestFileAuditSink_RotateWithoutBackups(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	if _, err := NewFileAuditSink(name, 150, 0); err == nil {
+		t.Fatal("expected rotation without backups to be rejected")
+	}
+
+	s, err := NewFileAuditSink(name, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// a sink changed to rotate without backups still keeps one old file
+	s.MaxBytes = 150
+	for i := 0; i < 3; i++ {
+		if err := s.WriteAudit(AuditRecord{Method: "POST", Path: fmt.Sprintf("/projects/p%d/devices", i), Status: 201}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b, err := os.ReadFile(name + ".1"); err != nil || !bytes.Contains(b, []byte("/projects/p1/devices")) {
+		t.Errorf("expected the previous records in %s.1, got %q, %v", name, b, err)
+	}
+}
+
+func TestFileAuditSink_RotateFailure(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	s, err := NewFileAuditSink(name, 150, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// name.1 can not be replaced while it is a directory with content
+	if err := os.MkdirAll(filepath.Join(name+".1", "blocker"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	write := func(i int) error {
+		return s.WriteAudit(AuditRecord{Method: "POST", Path: fmt.Sprintf("/projects/p%d/devices", i), Status: 201})
+	}
+	if err := write(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := write(1); err == nil {
+		t.Fatal("expected the rotation to fail")
+	}
+
+	// the record is still written, and writing continues once the rotation
+	// succeeds
+	if err := os.RemoveAll(name + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := write(2); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(name + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("/projects/p0/devices")) || !bytes.Contains(b, []byte("/projects/p1/devices")) {
+		t.Errorf("expected the records written before the rotation in %s.1, got %s", name, b)
+	}
+	if b, err := os.ReadFile(name); err != nil || !bytes.Contains(b, []byte("/projects/p2/devices")) {
+		t.Errorf("expected the latest record in %s, got %s, %v", name, b, err)
+	}
+}
@@ -0,0 +1,378 @@
+package packngo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// BandwidthReportChunk is the default longest time range requested from
+// GetBandwidth in a single call
+const BandwidthReportChunk = 24 * time.Hour
+
+// BandwidthReportOpts selects the time window and devices of a bandwidth report
+type BandwidthReportOpts struct {
+	From  time.Time
+	Until time.Time
+
+	// Chunk splits long windows into several GetBandwidth calls. Defaults to
+	// BandwidthReportChunk.
+	Chunk time.Duration
+
+	// Step is the expected interval between datapoints. Datapoints are
+	// aligned to samples every Step from From to Until, and missing samples
+	// are filled. When zero, it is inferred from the datapoints.
+	Step time.Duration
+
+	// Tag limits project reports to devices carrying the tag
+	Tag string
+}
+
+// BandwidthStats summarizes the traffic of one direction
+type BandwidthStats struct {
+	Direction BandwidthTarget `json:"direction"`
+
+	// Samples is the number of datapoints after gap filling
+	Samples int `json:"samples"`
+
+	// Gaps is the number of samples that were missing or null and were
+	// interpolated
+	Gaps int `json:"gaps"`
+
+	// TotalBytes is the volume transferred from From to Until
+	TotalBytes float64 `json:"total_bytes"`
+
+	// AverageRate, PeakRate and P95Rate are in bytes per second
+	AverageRate float64   `json:"average_rate"`
+	PeakRate    float64   `json:"peak_rate"`
+	PeakTime    time.Time `json:"peak_time"`
+	P95Rate     float64   `json:"p95_rate"`
+}
+
+// DeviceBandwidthReport is the bandwidth summary of a device
+type DeviceBandwidthReport struct {
+	DeviceID string         `json:"device_id"`
+	Hostname string         `json:"hostname,omitempty"`
+	Inbound  BandwidthStats `json:"inbound"`
+	Outbound BandwidthStats `json:"outbound"`
+
+	inbound, outbound bandwidthSeries
+}
+
+// BandwidthReport is the bandwidth summary of a set of devices. Inbound and
+// Outbound are computed from the per-sample sum of all devices, so the
+// 95th percentile reflects the aggregate traffic.
+type BandwidthReport struct {
+	From     time.Time               `json:"from"`
+	Until    time.Time               `json:"until"`
+	Devices  []DeviceBandwidthReport `json:"devices"`
+	Inbound  BandwidthStats          `json:"inbound"`
+	Outbound BandwidthStats          `json:"outbound"`
+}
+
+type bandwidthSample struct {
+	when time.Time
+	rate float64
+
+	// gap is set for interpolated samples
+	gap bool
+}
+
+type bandwidthSeries []bandwidthSample
+
+// fetchBandwidth retrieves the datapoints of a device, one chunk at a time
+func fetchBandwidth(s DeviceService, deviceID string, opts *BandwidthReportOpts) (in, out []Datapoint, err error) {
+	chunk := opts.Chunk
+	if chunk <= 0 {
+		chunk = BandwidthReportChunk
+	}
+	for from := opts.From; from.Before(opts.Until); from = from.Add(chunk) {
+		until := from.Add(chunk)
+		if until.After(opts.Until) {
+			until = opts.Until
+		}
+		bw, _, err := s.GetBandwidth(deviceID, &BandwidthOpts{From: &Timestamp{from}, Until: &Timestamp{until}})
+		if err != nil {
+			return nil, nil, err
+		}
+		in = append(in, bw.Inbound.Datapoints...)
+		out = append(out, bw.Outbound.Datapoints...)
+	}
+	return in, out, nil
+}
+
+// inferStep returns the smallest interval between consecutive datapoints
+func inferStep(points []Datapoint) time.Duration {
+	sorted := append([]Datapoint{}, points...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].When.Before(sorted[j].When.Time) })
+	var step time.Duration
+	for i := 1; i < len(sorted); i++ {
+		d := sorted[i].When.Sub(sorted[i-1].When.Time)
+		if d > 0 && (step == 0 || d < step) {
+			step = d
+		}
+	}
+	return step
+}
+
+// fillBandwidth aligns datapoints to the samples at From, From+step, ...
+// Until of the report window, averaging the datapoints nearest to each
+// sample. Samples without a datapoint, including those at the edges of the
+// window, and null rates are linearly interpolated from their neighbours. It
+// returns the filled series and the number of interpolated samples.
+func fillBandwidth(points []Datapoint, from, until time.Time, step time.Duration) (bandwidthSeries, int) {
+	if step <= 0 {
+		step = until.Sub(from)
+	}
+	n := int(until.Sub(from)/step) + 1
+	sums, counts := make([]float64, n), make([]int, n)
+	for _, p := range points {
+		offset := p.When.Sub(from) + step/2
+		if p.Rate == nil || offset < 0 {
+			continue
+		}
+		if k := int(offset / step); k < n {
+			sums[k] += *p.Rate
+			counts[k]++
+		}
+	}
+
+	series := make(bandwidthSeries, n)
+	gaps := 0
+	for k := range series {
+		series[k].when = from.Add(time.Duration(k) * step)
+		if counts[k] > 0 {
+			series[k].rate = sums[k] / float64(counts[k])
+			continue
+		}
+		series[k].gap = true
+		gaps++
+	}
+
+	for k := range series {
+		if !series[k].gap {
+			continue
+		}
+		prev, next := -1, -1
+		for j := k - 1; j >= 0; j-- {
+			if !series[j].gap {
+				prev = j
+				break
+			}
+		}
+		for j := k + 1; j < n; j++ {
+			if !series[j].gap {
+				next = j
+				break
+			}
+		}
+		switch {
+		case prev >= 0 && next >= 0:
+			frac := float64(k-prev) / float64(next-prev)
+			series[k].rate = series[prev].rate + frac*(series[next].rate-series[prev].rate)
+		case prev >= 0:
+			series[k].rate = series[prev].rate
+		case next >= 0:
+			series[k].rate = series[next].rate
+		}
+	}
+	return series, gaps
+}
+
+// stats summarizes a filled series sampled every step
+func (series bandwidthSeries) stats(direction BandwidthTarget, step time.Duration, gaps int) BandwidthStats {
+	st := BandwidthStats{Direction: direction, Samples: len(series), Gaps: gaps}
+	if len(series) == 0 {
+		return st
+	}
+
+	rates := make([]float64, len(series))
+	sum := 0.0
+	for i, s := range series {
+		rates[i] = s.rate
+		sum += s.rate
+		if s.rate > st.PeakRate || i == 0 {
+			st.PeakRate, st.PeakTime = s.rate, s.when
+		}
+		// the samples are the ends of the steps of the window, so the
+		// volume is integrated with trapezoids
+		if i > 0 {
+			st.TotalBytes += (series[i-1].rate + s.rate) / 2 * step.Seconds()
+		}
+	}
+	st.AverageRate = sum / float64(len(series))
+
+	sort.Float64s(rates)
+	rank := int(math.Ceil(0.95*float64(len(rates)))) - 1
+	st.P95Rate = rates[rank]
+	return st
+}
+
+// deviceBandwidthData are the datapoints of a device
+type deviceBandwidthData struct {
+	device  Device
+	in, out []Datapoint
+}
+
+// step returns the smallest interval between datapoints of the device
+func (d *deviceBandwidthData) step() time.Duration {
+	step := inferStep(d.in)
+	if outStep := inferStep(d.out); step == 0 || (outStep != 0 && outStep < step) {
+		step = outStep
+	}
+	return step
+}
+
+// report fills and summarizes the datapoints sampled every step
+func (d *deviceBandwidthData) report(opts *BandwidthReportOpts, step time.Duration) *DeviceBandwidthReport {
+	if step <= 0 {
+		step = opts.Until.Sub(opts.From)
+	}
+	r := &DeviceBandwidthReport{DeviceID: d.device.ID, Hostname: d.device.Hostname}
+	var inGaps, outGaps int
+	r.inbound, inGaps = fillBandwidth(d.in, opts.From, opts.Until, step)
+	r.outbound, outGaps = fillBandwidth(d.out, opts.From, opts.Until, step)
+	r.Inbound = r.inbound.stats(BandwidthInbound, step, inGaps)
+	r.Outbound = r.outbound.stats(BandwidthOutbound, step, outGaps)
+	return r
+}
+
+// DeviceBandwidth fetches the bandwidth of a device over the report window
+// and summarizes each direction
+func DeviceBandwidth(s DeviceService, deviceID string, opts *BandwidthReportOpts) (*DeviceBandwidthReport, error) {
+	if opts == nil || !opts.From.Before(opts.Until) {
+		return nil, fmt.Errorf("bandwidth report requires From before Until")
+	}
+	data := &deviceBandwidthData{device: Device{ID: deviceID}}
+	var err error
+	if data.in, data.out, err = fetchBandwidth(s, deviceID, opts); err != nil {
+		return nil, err
+	}
+
+	step := opts.Step
+	if step == 0 {
+		step = data.step()
+	}
+	return data.report(opts, step), nil
+}
+
+// ProjectBandwidth summarizes the bandwidth of every device in a project, or
+// of the devices carrying opts.Tag, and of the project as a whole. All
+// devices are sampled at the same step, the smallest one found when
+// opts.Step is zero. An aggregate sample is counted as a gap when it was
+// interpolated for any device.
+func ProjectBandwidth(s DeviceService, projectID string, opts *BandwidthReportOpts) (*BandwidthReport, error) {
+	if opts == nil || !opts.From.Before(opts.Until) {
+		return nil, fmt.Errorf("bandwidth report requires From before Until")
+	}
+	devices, _, err := s.List(projectID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	step := opts.Step
+	var data []*deviceBandwidthData
+	for _, d := range devices {
+		if opts.Tag != "" && !contains(d.Tags, opts.Tag) {
+			continue
+		}
+		dd := &deviceBandwidthData{device: d}
+		if dd.in, dd.out, err = fetchBandwidth(s, d.ID, opts); err != nil {
+			return nil, err
+		}
+		if opts.Step == 0 {
+			if devStep := dd.step(); devStep != 0 && (step == 0 || devStep < step) {
+				step = devStep
+			}
+		}
+		data = append(data, dd)
+	}
+	if step <= 0 {
+		step = opts.Until.Sub(opts.From)
+	}
+
+	report := &BandwidthReport{From: opts.From, Until: opts.Until}
+	var inSum, outSum bandwidthSeries
+	for _, dd := range data {
+		dr := dd.report(opts, step)
+		report.Devices = append(report.Devices, *dr)
+		inSum = addBandwidthSeries(inSum, dr.inbound)
+		outSum = addBandwidthSeries(outSum, dr.outbound)
+	}
+
+	report.Inbound = inSum.stats(BandwidthInbound, step, inSum.gaps())
+	report.Outbound = outSum.stats(BandwidthOutbound, step, outSum.gaps())
+	return report, nil
+}
+
+// addBandwidthSeries adds the rates of b to sum, both sampled at the same
+// times
+func addBandwidthSeries(sum, b bandwidthSeries) bandwidthSeries {
+	if sum == nil {
+		return append(bandwidthSeries{}, b...)
+	}
+	for i := range sum {
+		sum[i].rate += b[i].rate
+		sum[i].gap = sum[i].gap || b[i].gap
+	}
+	return sum
+}
+
+// gaps counts the interpolated samples
+func (series bandwidthSeries) gaps() int {
+	gaps := 0
+	for _, s := range series {
+		if s.gap {
+			gaps++
+		}
+	}
+	return gaps
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *BandwidthReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes one row per device and direction, followed by the
+// aggregate rows with an empty device_id
+func (r *BandwidthReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"device_id", "hostname", "direction", "from", "until", "samples", "gaps", "total_bytes", "average_rate", "peak_rate", "peak_time", "p95_rate"}); err != nil {
+		return err
+	}
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	row := func(id, hostname string, st BandwidthStats) error {
+		return cw.Write([]string{
+			id, hostname, string(st.Direction),
+			r.From.UTC().Format(time.RFC3339), r.Until.UTC().Format(time.RFC3339),
+			strconv.Itoa(st.Samples), strconv.Itoa(st.Gaps),
+			f(st.TotalBytes), f(st.AverageRate), f(st.PeakRate),
+			st.PeakTime.UTC().Format(time.RFC3339), f(st.P95Rate),
+		})
+	}
+	for _, d := range r.Devices {
+		if err := row(d.DeviceID, d.Hostname, d.Inbound); err != nil {
+			return err
+		}
+		if err := row(d.DeviceID, d.Hostname, d.Outbound); err != nil {
+			return err
+		}
+	}
+	if err := row("", "", r.Inbound); err != nil {
+		return err
+	}
+	if err := row("", "", r.Outbound); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
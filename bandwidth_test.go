@@ -0,0 +1,169 @@
+package packngo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// fakeBandwidthDevices serves a constant datapoint every minute, with the
+// rate at 00:02 missing and the rate at 00:03 null
+type fakeBandwidthDevices struct {
+	DeviceService
+	calls int
+}
+
+func (f *fakeBandwidthDevices) List(projectID string, opts *ListOptions) ([]Device, *Response, error) {
+	return []Device{
+		{ID: "dev-a", Hostname: "a", Tags: []string{"web"}},
+		{ID: "dev-b", Hostname: "b", Tags: []string{"web"}},
+		{ID: "dev-c", Hostname: "c"},
+	}, nil, nil
+}
+
+func (f *fakeBandwidthDevices) GetBandwidth(deviceID string, opts *BandwidthOpts) (*BandwidthIO, *Response, error) {
+	f.calls++
+	bw := &BandwidthIO{
+		Inbound:  BandwidthComponent{Target: BandwidthInbound},
+		Outbound: BandwidthComponent{Target: BandwidthOutbound},
+	}
+	for t := opts.From.Time; !t.After(opts.Until.Time); t = t.Add(time.Minute) {
+		min := t.Minute()
+		if min == 2 {
+			continue
+		}
+		in, out := float64(100*(min+1)), 10.0
+		dpIn := Datapoint{Rate: &in, When: Timestamp{t}}
+		if min == 3 {
+			dpIn.Rate = nil
+		}
+		bw.Inbound.Datapoints = append(bw.Inbound.Datapoints, dpIn)
+		bw.Outbound.Datapoints = append(bw.Outbound.Datapoints, Datapoint{Rate: &out, When: Timestamp{t}})
+	}
+	return bw, nil, nil
+}
+
+func TestDeviceBandwidth(t *testing.T) {
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &fakeBandwidthDevices{}
+	r, err := DeviceBandwidth(s, "dev-a", &BandwidthReportOpts{From: from, Until: from.Add(9 * time.Minute), Chunk: 3 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.calls != 3 {
+		t.Errorf("GetBandwidth calls = %d, want 3", s.calls)
+	}
+
+	// rates 100..1000 at minutes 0..9; 00:02 and 00:03 are interpolated from
+	// their neighbours, so the series is unchanged. The nine minutes of the
+	// window carry an average of 550 per second.
+	in := r.Inbound
+	if in.Samples != 10 || in.Gaps != 2 {
+		t.Errorf("inbound samples, gaps = %d, %d, want 10, 2", in.Samples, in.Gaps)
+	}
+	if in.AverageRate != 550 || in.TotalBytes != 550*9*60 {
+		t.Errorf("inbound average, total = %v, %v", in.AverageRate, in.TotalBytes)
+	}
+	if in.PeakRate != 1000 || !in.PeakTime.Equal(from.Add(9*time.Minute)) {
+		t.Errorf("inbound peak = %v at %v", in.PeakRate, in.PeakTime)
+	}
+	if in.P95Rate != 1000 {
+		t.Errorf("inbound p95 = %v, want 1000", in.P95Rate)
+	}
+	if r.Outbound.Samples != 10 || r.Outbound.Gaps != 1 || r.Outbound.AverageRate != 10 {
+		t.Errorf("unexpected outbound stats %s", Stringify(r.Outbound))
+	}
+}
+
+func TestProjectBandwidth(t *testing.T) {
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	r, err := ProjectBandwidth(&fakeBandwidthDevices{}, "project", &BandwidthReportOpts{From: from, Until: from.Add(9 * time.Minute), Tag: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Devices) != 2 {
+		t.Fatalf("devices = %d, want 2", len(r.Devices))
+	}
+	if r.Outbound.AverageRate != 20 || r.Inbound.PeakRate != 2000 {
+		t.Errorf("unexpected aggregate stats %s %s", Stringify(r.Inbound), Stringify(r.Outbound))
+	}
+
+	buf := new(bytes.Buffer)
+	if err := r.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 7 || rows[1][0] != "dev-a" || rows[1][2] != "inbound" || rows[6][0] != "" {
+		t.Errorf("unexpected csv %v", rows)
+	}
+
+	buf.Reset()
+	if err := r.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded BandwidthReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Inbound.P95Rate != r.Inbound.P95Rate {
+		t.Errorf("json p95 = %v, want %v", decoded.Inbound.P95Rate, r.Inbound.P95Rate)
+	}
+}
+
+// fakeSparseBandwidthDevices serves datapoints every minute between 00:02
+// and 00:07, offset by a few seconds per device
+type fakeSparseBandwidthDevices struct {
+	DeviceService
+}
+
+func (f *fakeSparseBandwidthDevices) List(projectID string, opts *ListOptions) ([]Device, *Response, error) {
+	return []Device{{ID: "dev-a"}, {ID: "dev-b"}}, nil, nil
+}
+
+func (f *fakeSparseBandwidthDevices) GetBandwidth(deviceID string, opts *BandwidthOpts) (*BandwidthIO, *Response, error) {
+	offset := 5 * time.Second
+	if deviceID == "dev-b" {
+		offset = 20 * time.Second
+	}
+	bw := &BandwidthIO{}
+	start := time.Date(2021, 1, 1, 0, 2, 0, 0, time.UTC)
+	for t := start; t.Before(start.Add(6 * time.Minute)); t = t.Add(time.Minute) {
+		rate := 100.0
+		if deviceID == "dev-b" && t.Minute() == 4 {
+			continue
+		}
+		bw.Inbound.Datapoints = append(bw.Inbound.Datapoints, Datapoint{Rate: &rate, When: Timestamp{t.Add(offset)}})
+	}
+	return bw, nil, nil
+}
+
+func TestProjectBandwidth_AlignsAndFillsEdges(t *testing.T) {
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	r, err := ProjectBandwidth(&fakeSparseBandwidthDevices{}, "project", &BandwidthReportOpts{From: from, Until: from.Add(9 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 00:00, 00:01, 00:08 and 00:09 are outside the datapoints of both
+	// devices, 00:04 is missing for dev-b
+	a, b := r.Devices[0].Inbound, r.Devices[1].Inbound
+	if a.Samples != 10 || a.Gaps != 4 || b.Samples != 10 || b.Gaps != 5 {
+		t.Errorf("device samples, gaps = %d, %d and %d, %d, want 10, 4 and 10, 5", a.Samples, a.Gaps, b.Samples, b.Gaps)
+	}
+	if a.AverageRate != 100 || a.TotalBytes != 100*60*9 {
+		t.Errorf("device average, total = %v, %v, want 100, 54000", a.AverageRate, a.TotalBytes)
+	}
+
+	in := r.Inbound
+	if in.Samples != 10 || in.Gaps != 5 {
+		t.Errorf("aggregate samples, gaps = %d, %d, want 10, 5", in.Samples, in.Gaps)
+	}
+	if in.AverageRate != 200 || in.PeakRate != 200 {
+		t.Errorf("aggregate average, peak = %v, %v, want 200, 200", in.AverageRate, in.PeakRate)
+	}
+}
@@ -0,0 +1,309 @@
+package packngo
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Batch states
+const (
+	BatchCompleted = "completed"
+	BatchFailed    = "failed"
+)
+
+const (
+	// BatchWaitTimeout is the default time CreateBatchesAndWait waits for
+	// batches to complete
+	BatchWaitTimeout = 30 * time.Minute
+
+	// BatchWaitCheck is the default interval between batch state checks
+	BatchWaitCheck = 10 * time.Second
+)
+
+// BatchTemplateData is passed to the hostname, tag and userdata templates of
+// ExpandBatchTemplates
+type BatchTemplateData struct {
+	// Index is the 1-based position of the device within its batch entry
+	Index int
+
+	// Ordinal is the 1-based position of the device across all entries
+	Ordinal int
+
+	// Quantity is the number of devices of the batch entry
+	Quantity int
+
+	Plan     string
+	Metro    string
+	Facility string
+}
+
+// ExpandBatchTemplates renders the Hostname, Tags, UserData and CustomData of
+// each batch entry as text/template templates, e.g. "web-{{.Index}}-{{.Metro}}".
+// Entries using templates are expanded into one entry of quantity one per
+// device; entries without templates are kept as they are.
+func ExpandBatchTemplates(request *BatchCreateRequest) (*BatchCreateRequest, error) {
+	expanded := &BatchCreateRequest{}
+	ordinal := 0
+	for i, b := range request.Batches {
+		quantity := int(b.Quantity)
+		if quantity < 1 {
+			quantity = 1
+		}
+		if !batchEntryTemplated(b) {
+			expanded.Batches = append(expanded.Batches, b)
+			ordinal += quantity
+			continue
+		}
+
+		tpl, err := parseBatchEntry(b)
+		if err != nil {
+			return nil, fmt.Errorf("batch %d: %w", i, err)
+		}
+		data := BatchTemplateData{Quantity: quantity, Plan: b.Plan, Metro: b.Metro}
+		if len(b.Facility) > 0 {
+			data.Facility = b.Facility[0]
+		}
+		for n := 1; n <= quantity; n++ {
+			ordinal++
+			data.Index, data.Ordinal = n, ordinal
+			d := b
+			d.Quantity = 1
+			if b.Tags != nil {
+				d.Tags = make([]string, len(b.Tags))
+			}
+			if err := tpl.render(&d, data); err != nil {
+				return nil, fmt.Errorf("batch %d device %d: %w", i, n, err)
+			}
+			expanded.Batches = append(expanded.Batches, d)
+		}
+	}
+	return expanded, nil
+}
+
+func batchEntryTemplated(b BatchCreateDevice) bool {
+	fields := append([]string{b.Hostname, b.UserData, b.CustomData}, b.Tags...)
+	for _, f := range fields {
+		if strings.Contains(f, "{{") {
+			return true
+		}
+	}
+	return false
+}
+
+type batchEntryTemplate struct {
+	hostname, userdata, customdata *template.Template
+	tags                           []*template.Template
+}
+
+func parseBatchEntry(b BatchCreateDevice) (*batchEntryTemplate, error) {
+	parse := func(name, text string) (*template.Template, error) {
+		t, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s template: %w", name, err)
+		}
+		return t, nil
+	}
+
+	tpl := &batchEntryTemplate{}
+	var err error
+	if tpl.hostname, err = parse("hostname", b.Hostname); err != nil {
+		return nil, err
+	}
+	if tpl.userdata, err = parse("userdata", b.UserData); err != nil {
+		return nil, err
+	}
+	if tpl.customdata, err = parse("customdata", b.CustomData); err != nil {
+		return nil, err
+	}
+	for i, tag := range b.Tags {
+		t, err := parse(fmt.Sprintf("tags[%d]", i), tag)
+		if err != nil {
+			return nil, err
+		}
+		tpl.tags = append(tpl.tags, t)
+	}
+	return tpl, nil
+}
+
+func (tpl *batchEntryTemplate) render(d *BatchCreateDevice, data BatchTemplateData) error {
+	exec := func(t *template.Template) (string, error) {
+		buf := new(bytes.Buffer)
+		if err := t.Execute(buf, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	var err error
+	if d.Hostname, err = exec(tpl.hostname); err != nil {
+		return err
+	}
+	if d.Hostname != "" {
+		if err := ValidateHostname(d.Hostname); err != nil {
+			return err
+		}
+	}
+	if d.UserData, err = exec(tpl.userdata); err != nil {
+		return err
+	}
+	if d.CustomData, err = exec(tpl.customdata); err != nil {
+		return err
+	}
+	for i, t := range tpl.tags {
+		if d.Tags[i], err = exec(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BatchWaitOpts configures CreateBatchesAndWait
+type BatchWaitOpts struct {
+	// Timeout defaults to BatchWaitTimeout
+	Timeout time.Duration
+
+	// PollInterval defaults to BatchWaitCheck
+	PollInterval time.Duration
+
+	// DeleteOnFailure deletes the failed batches, and the batches still in
+	// progress at the timeout, together with their devices. Completed
+	// batches and their devices are kept.
+	DeleteOnFailure bool
+}
+
+// BatchResult collects the outcome of CreateBatchesAndWait
+type BatchResult struct {
+	Batches       []Batch
+	Devices       []Device
+	ErrorMessages []string
+
+	// Deleted lists the IDs of the batches deleted after a failure. Their
+	// devices are not in Devices.
+	Deleted []string
+}
+
+// BatchError is returned by CreateBatchesAndWait when a batch failed or did
+// not complete in time
+type BatchError struct {
+	Result *BatchResult
+
+	// Pending lists the IDs of batches still in progress at the timeout
+	Pending []string
+}
+
+func (e *BatchError) Error() string {
+	msg := fmt.Sprintf("batch create failed: %s", strings.Join(e.Result.ErrorMessages, "; "))
+	if len(e.Pending) > 0 {
+		msg = fmt.Sprintf("timed out waiting for batches %s", strings.Join(e.Pending, ", "))
+	}
+	if len(e.Result.Deleted) > 0 {
+		msg += fmt.Sprintf(" (deleted batches %s)", strings.Join(e.Result.Deleted, ", "))
+	}
+	return msg
+}
+
+// CreateBatchesAndWait expands the templates of request with
+// ExpandBatchTemplates, creates the batches and polls each one until it is
+// completed or failed. The devices and error messages of all batches are
+// collected in the result. A failure or timeout is reported as a *BatchError
+// alongside the result.
+func CreateBatchesAndWait(s BatchService, projectID string, request *BatchCreateRequest, opts *BatchWaitOpts) (*BatchResult, *Response, error) {
+	if opts == nil {
+		opts = &BatchWaitOpts{}
+	}
+	timeout, interval := opts.Timeout, opts.PollInterval
+	if timeout == 0 {
+		timeout = BatchWaitTimeout
+	}
+	if interval == 0 {
+		interval = BatchWaitCheck
+	}
+
+	expanded, err := ExpandBatchTemplates(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	batches, resp, err := s.Create(projectID, expanded)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	getOpts := &GetOptions{Includes: []string{"devices"}}
+	done := map[string]bool{}
+	for {
+		for i, b := range batches {
+			if done[b.ID] {
+				continue
+			}
+			latest, r, err := s.Get(b.ID, getOpts)
+			if err != nil {
+				return nil, r, err
+			}
+			batches[i], resp = *latest, r
+			if latest.State == BatchCompleted || latest.State == BatchFailed {
+				done[b.ID] = true
+			}
+		}
+		if len(done) == len(batches) {
+			break
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			var pending []string
+			for _, b := range batches {
+				if !done[b.ID] {
+					pending = append(pending, b.ID)
+				}
+			}
+			return batchFailure(s, batches, pending, opts)
+		}
+	}
+
+	for _, b := range batches {
+		if b.State == BatchFailed {
+			return batchFailure(s, batches, nil, opts)
+		}
+	}
+	return collectBatches(batches), resp, nil
+}
+
+func collectBatches(batches []Batch) *BatchResult {
+	result := &BatchResult{Batches: batches}
+	for _, b := range batches {
+		result.Devices = append(result.Devices, b.Devices...)
+		result.ErrorMessages = append(result.ErrorMessages, b.ErrorMessages...)
+	}
+	return result
+}
+
+// batchFailure optionally deletes the batches of a failed
+// CreateBatchesAndWait that did not complete
+func batchFailure(s BatchService, batches []Batch, pending []string, opts *BatchWaitOpts) (*BatchResult, *Response, error) {
+	result := collectBatches(batches)
+	var resp *Response
+	if opts.DeleteOnFailure {
+		result.Devices = nil
+		for _, b := range batches {
+			if b.State == BatchCompleted {
+				result.Devices = append(result.Devices, b.Devices...)
+				continue
+			}
+			r, err := s.Delete(b.ID, true)
+			resp = r
+			if err != nil {
+				return result, resp, fmt.Errorf("deleting batch %s after failure: %w", b.ID, err)
+			}
+			result.Deleted = append(result.Deleted, b.ID)
+		}
+	}
+	return result, resp, &BatchError{Result: result, Pending: pending}
+}
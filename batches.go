@@ -50,9 +50,11 @@ type BatchCreateDevice struct {
 
 // BatchServiceOp implements BatchService
 type BatchServiceOp struct {
-	client *Client
+	client requestDoer
 }
 
+var _ BatchService = (*BatchServiceOp)(nil)
+
 // Get returns batch details
 func (s *BatchServiceOp) Get(batchID string, opts *GetOptions) (*Batch, *Response, error) {
 	if validateErr := ValidateUUID(batchID); validateErr != nil {
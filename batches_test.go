@@ -1,8 +1,12 @@
 package packngo
 
 import (
+	"path"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestAccInstanceBatches(t *testing.T) {
@@ -67,3 +71,145 @@ func TestAccInstanceBatches(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestExpandBatchTemplates(t *testing.T) {
+	req := &BatchCreateRequest{Batches: []BatchCreateDevice{
+		{
+			DeviceCreateRequest: DeviceCreateRequest{
+				Hostname: "web-{{.Index}}-{{.Metro}}",
+				Metro:    "sv",
+				Plan:     "c3.small.x86",
+				Tags:     []string{"web", "shard-{{.Ordinal}}"},
+				UserData: "#!/bin/sh\necho {{.Index}}/{{.Quantity}}",
+			},
+			Quantity: 2,
+		},
+		{
+			DeviceCreateRequest: DeviceCreateRequest{Hostname: "db", Metro: "da"},
+			Quantity:            3,
+		},
+		{
+			DeviceCreateRequest: DeviceCreateRequest{Hostname: "cache-{{.Ordinal}}", Facility: []string{"da11"}},
+		},
+	}}
+
+	got, err := ExpandBatchTemplates(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BatchCreateDevice{
+		{DeviceCreateRequest: DeviceCreateRequest{Hostname: "web-1-sv", Metro: "sv", Plan: "c3.small.x86", Tags: []string{"web", "shard-1"}, UserData: "#!/bin/sh\necho 1/2"}, Quantity: 1},
+		{DeviceCreateRequest: DeviceCreateRequest{Hostname: "web-2-sv", Metro: "sv", Plan: "c3.small.x86", Tags: []string{"web", "shard-2"}, UserData: "#!/bin/sh\necho 2/2"}, Quantity: 1},
+		{DeviceCreateRequest: DeviceCreateRequest{Hostname: "db", Metro: "da"}, Quantity: 3},
+		{DeviceCreateRequest: DeviceCreateRequest{Hostname: "cache-6", Facility: []string{"da11"}}, Quantity: 1},
+	}
+	if diff := cmp.Diff(want, got.Batches); diff != "" {
+		t.Errorf("ExpandBatchTemplates() mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, hostname := range []string{"web-{{.Bogus}}", "web-{{.Index", "web_{{.Index}}"} {
+		req := &BatchCreateRequest{Batches: []BatchCreateDevice{{DeviceCreateRequest: DeviceCreateRequest{Hostname: hostname}}}}
+		if _, err := ExpandBatchTemplates(req); err == nil {
+			t.Errorf("expected error for hostname template %q", hostname)
+		}
+	}
+}
+
+func TestCreateBatchesAndWait(t *testing.T) {
+	const (
+		projectID = "93125c2a-8b78-4d4f-a3c4-7367d6b7cca8"
+		batchA    = "0a6f1c8e-2c1b-4d8e-9a41-0f1f6e7b9a01"
+		batchB    = "0a6f1c8e-2c1b-4d8e-9a41-0f1f6e7b9a02"
+	)
+	req := &BatchCreateRequest{Batches: []BatchCreateDevice{
+		{DeviceCreateRequest: DeviceCreateRequest{Hostname: "web-{{.Index}}"}, Quantity: 2},
+	}}
+
+	tests := []struct {
+		name        string
+		finalB      string
+		opts        BatchWaitOpts
+		wantErr     bool
+		wantDeleted []string
+	}{
+		{name: "Completed", finalB: BatchCompleted},
+		{name: "Failed", finalB: BatchFailed, wantErr: true},
+		{name: "FailedDelete", finalB: BatchFailed, opts: BatchWaitOpts{DeleteOnFailure: true}, wantErr: true, wantDeleted: []string{batchB}},
+		{name: "Timeout", finalB: "", opts: BatchWaitOpts{Timeout: 5 * time.Millisecond}, wantErr: true},
+		{name: "TimeoutDelete", finalB: "", opts: BatchWaitOpts{Timeout: 5 * time.Millisecond, DeleteOnFailure: true}, wantErr: true, wantDeleted: []string{batchB}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *BatchCreateRequest
+			var deleted []string
+			gets := map[string]int{}
+			s := &BatchServiceOp{client: &MockClient{
+				fnDoRequest: func(method, pathURL string, body, v interface{}) (*Response, error) {
+					switch method {
+					case "POST":
+						created = body.(*BatchCreateRequest)
+						v.(*batchesList).Batches = []Batch{{ID: batchA}, {ID: batchB}}
+					case "GET":
+						id := path.Base(strings.Split(pathURL, "?")[0])
+						gets[id]++
+						b := v.(*Batch)
+						*b = Batch{ID: id, State: BatchCompleted, Devices: []Device{{ID: "dev-" + id}}}
+						if id == batchB {
+							b.State = ""
+							if gets[id] > 1 {
+								b.State = tt.finalB
+							}
+							if b.State == BatchFailed {
+								b.ErrorMessages = []string{"no capacity"}
+								b.Devices = nil
+							}
+						}
+					case "DELETE":
+						if !strings.HasSuffix(pathURL, "?remove_associated_instances=true") {
+							t.Errorf("unexpected delete %s", pathURL)
+						}
+						deleted = append(deleted, path.Base(strings.Split(pathURL, "?")[0]))
+					}
+					return mockResponse(200, "", nil), nil
+				},
+			}}
+
+			opts := tt.opts
+			opts.PollInterval = time.Millisecond
+			result, _, err := CreateBatchesAndWait(s, projectID, req, &opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateBatchesAndWait() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(created.Batches) != 2 || created.Batches[1].Hostname != "web-2" {
+				t.Errorf("unexpected created batches %+v", created.Batches)
+			}
+			if gets[batchA] != 1 {
+				t.Errorf("completed batch polled %d times", gets[batchA])
+			}
+			if diff := cmp.Diff(tt.wantDeleted, deleted); diff != "" {
+				t.Errorf("deleted batches mismatch (-want +got):\n%s", diff)
+			}
+			if tt.finalB == BatchCompleted && len(result.Devices) != 2 {
+				t.Errorf("expected 2 devices, got %+v", result.Devices)
+			}
+			if tt.finalB == BatchFailed {
+				batchErr, ok := err.(*BatchError)
+				if !ok || len(batchErr.Result.ErrorMessages) != 1 {
+					t.Errorf("unexpected error %#v", err)
+				}
+			}
+			if tt.wantDeleted != nil {
+				// the devices of the completed batch are kept
+				batchErr, ok := err.(*BatchError)
+				if !ok || cmp.Diff(tt.wantDeleted, batchErr.Result.Deleted) != "" || len(result.Devices) != 1 || result.Devices[0].ID != "dev-"+batchA {
+					t.Errorf("unexpected result %+v, %v", result, err)
+				}
+			}
+			if strings.HasPrefix(tt.name, "Timeout") {
+				if batchErr, ok := err.(*BatchError); !ok || len(batchErr.Pending) != 1 {
+					t.Errorf("expected pending batch in %#v", err)
+				}
+			}
+		})
+	}
+}
@@ -55,6 +55,10 @@ type BGPNeighbor struct {
 	RoutesOut     []BGPRoute `json:"routes_out"`
 }
 
+func (n BGPNeighbor) String() string {
+	return Stringify(n)
+}
+
 // BGPRoute is a struct for Route in BGP neighbor listing
 type BGPRoute struct {
 	Route string `json:"route"`
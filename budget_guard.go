@@ -0,0 +1,267 @@
+package packngo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BudgetExceededError scopes
+const (
+	BudgetScopeCall    = "call"
+	BudgetScopeProject = "project"
+)
+
+// BudgetLimits configures WithBudgetGuard. A zero ceiling is not enforced.
+type BudgetLimits struct {
+	// CallHourly caps the incremental hourly cost of a single create
+	CallHourly float64
+
+	// ProjectHourly caps the hourly run-rate of a project after a create.
+	// The run-rate is estimated with a CostEstimator on the first guarded
+	// create of a project and reused for RunRateTTL.
+	ProjectHourly float64
+
+	// Projects overrides ProjectHourly by project ID
+	Projects map[string]float64
+
+	// Rates is used to estimate the current project run-rate
+	Rates CostRates
+
+	// RunRateTTL is how long an estimated project run-rate is reused. The
+	// creates allowed meanwhile are added to it. Defaults to
+	// DefaultBudgetRunRateTTL.
+	RunRateTTL time.Duration
+}
+
+// DefaultBudgetRunRateTTL is the default BudgetLimits.RunRateTTL
+const DefaultBudgetRunRateTTL = 5 * time.Minute
+
+func (l BudgetLimits) projectHourly(projectID string) float64 {
+	if limit, ok := l.Projects[projectID]; ok {
+		return limit
+	}
+	return l.ProjectHourly
+}
+
+// BudgetExceededError is returned by guarded creates that would exceed a
+// ceiling of BudgetLimits. The request is not sent.
+type BudgetExceededError struct {
+	// Operation is the guarded call, e.g. "Devices.Create"
+	Operation string
+	ProjectID string
+
+	// Scope is BudgetScopeCall or BudgetScopeProject
+	Scope string
+
+	// Hourly is the estimated incremental hourly cost of the call
+	Hourly float64
+
+	// Current is the estimated project run-rate before the call. It is only
+	// set for BudgetScopeProject.
+	Current float64
+
+	Limit float64
+}
+
+func (e *BudgetExceededError) Error() string {
+	if e.Scope == BudgetScopeProject {
+		return fmt.Sprintf("%s would raise the hourly cost of project %s from %.2f to %.2f, over the budget of %.2f", e.Operation, e.ProjectID, e.Current, e.Current+e.Hourly, e.Limit)
+	}
+	return fmt.Sprintf("%s would add %.2f per hour, over the per-call budget of %.2f", e.Operation, e.Hourly, e.Limit)
+}
+
+// WithBudgetGuard estimates the incremental hourly cost of device, batch and
+// spot market request creates from plan pricing, and fails them with a
+// *BudgetExceededError before they are sent when a ceiling of limits would be
+// exceeded.
+//
+// Devices on a hardware reservation are free. Spot devices are priced at
+// their maximum bid, or at the on-demand price when no bid is set. Creates of
+// plans without pricing fail.
+func WithBudgetGuard(limits BudgetLimits) ClientOpt {
+	return func(c *Client) error {
+		estimator := NewCostEstimator(c, limits.Rates)
+		c.budget = &budgetGuard{
+			limits: limits,
+			plans:  c.Plans,
+			projectHourly: func(projectID string) (float64, error) {
+				estimate, err := estimator.Project(projectID)
+				if err != nil {
+					return 0, err
+				}
+				return estimate.Total.Hourly, nil
+			},
+		}
+
+		return nil
+	}
+}
+
+// checkBudget fails creates that would exceed the limits of WithBudgetGuard
+func (c *Client) checkBudget(method, apiPath string, body interface{}) error {
+	if c.budget == nil {
+		return nil
+	}
+	return c.budget.check(method, apiPath, body)
+}
+
+// budgetGuard checks create requests against limits
+type budgetGuard struct {
+	limits        BudgetLimits
+	plans         PlanService
+	projectHourly func(projectID string) (float64, error)
+
+	mu       sync.Mutex
+	prices   map[string]float64
+	runRates map[string]budgetRunRate
+
+	now func() time.Time
+}
+
+// budgetRunRate is a cached project run-rate
+type budgetRunRate struct {
+	hourly    float64
+	estimated time.Time
+}
+
+func (g *budgetGuard) check(method, apiPath string, body interface{}) error {
+	if method != "POST" {
+		return nil
+	}
+
+	var (
+		op, projectID string
+		hourly        float64
+		err           error
+	)
+	switch r := body.(type) {
+	case *DeviceCreateRequest:
+		op, projectID = "Devices.Create", r.ProjectID
+		hourly, err = g.devicePrice(r.Plan, r.HardwareReservationID, r.SpotInstance, r.SpotPriceMax)
+	case *BatchCreateRequest:
+		op, projectID = "Batches.Create", budgetPathProject(apiPath)
+		for _, b := range r.Batches {
+			var price float64
+			price, err = g.devicePrice(b.Plan, b.HardwareReservationID, b.SpotInstance, b.SpotPriceMax)
+			if err != nil {
+				break
+			}
+			hourly += float64(b.Quantity) * price
+		}
+	case *SpotMarketRequestCreateRequest:
+		op, projectID = "SpotMarketRequests.Create", budgetPathProject(apiPath)
+		var price float64
+		price, err = g.devicePrice(r.Parameters.Plan, "", true, r.MaxBidPrice)
+		hourly = float64(r.DevicesMax) * price
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if limit := g.limits.CallHourly; limit > 0 && hourly > limit {
+		return &BudgetExceededError{Operation: op, ProjectID: projectID, Scope: BudgetScopeCall, Hourly: hourly, Limit: limit}
+	}
+	if limit := g.limits.projectHourly(projectID); limit > 0 {
+		if err := g.refreshRunRate(projectID); err != nil {
+			return fmt.Errorf("%s: estimating cost of project %s: %w", op, projectID, err)
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		r := g.runRates[projectID]
+		if r.hourly+hourly > limit {
+			return &BudgetExceededError{Operation: op, ProjectID: projectID, Scope: BudgetScopeProject, Hourly: hourly, Current: r.hourly, Limit: limit}
+		}
+		r.hourly += hourly
+		g.runRates[projectID] = r
+	}
+	return nil
+}
+
+// refreshRunRate estimates the run-rate of a project when it is missing or
+// older than RunRateTTL. The estimate is made without holding g.mu, so that
+// guarded creates are not held up by it.
+func (g *budgetGuard) refreshRunRate(projectID string) error {
+	now := time.Now()
+	if g.now != nil {
+		now = g.now()
+	}
+	ttl := g.limits.RunRateTTL
+	if ttl <= 0 {
+		ttl = DefaultBudgetRunRateTTL
+	}
+	g.mu.Lock()
+	r, ok := g.runRates[projectID]
+	g.mu.Unlock()
+	if ok && now.Sub(r.estimated) < ttl {
+		return nil
+	}
+
+	hourly, err := g.projectHourly(projectID)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// keep an estimate made meanwhile by a concurrent create, as it also
+	// counts the creates allowed since
+	if r, ok := g.runRates[projectID]; ok && !r.estimated.Before(now) {
+		return nil
+	}
+	if g.runRates == nil {
+		g.runRates = map[string]budgetRunRate{}
+	}
+	g.runRates[projectID] = budgetRunRate{hourly: hourly, estimated: now}
+	return nil
+}
+
+// devicePrice returns the hourly price of one device of plan, which may be a
+// plan slug or ID
+func (g *budgetGuard) devicePrice(plan, hardwareReservationID string, spot bool, spotPriceMax float64) (float64, error) {
+	if hardwareReservationID != "" {
+		return 0, nil
+	}
+	if spot && spotPriceMax > 0 {
+		return spotPriceMax, nil
+	}
+
+	g.mu.Lock()
+	prices := g.prices
+	g.mu.Unlock()
+	if prices == nil {
+		plans, _, err := g.plans.List(nil)
+		if err != nil {
+			return 0, err
+		}
+		prices = map[string]float64{}
+		for _, p := range plans {
+			if price := pricingHourly(p.Pricing); price > 0 {
+				prices[p.Slug] = price
+				if p.ID != "" {
+					prices[p.ID] = price
+				}
+			}
+		}
+		g.mu.Lock()
+		g.prices = prices
+		g.mu.Unlock()
+	}
+	price, ok := prices[plan]
+	if !ok {
+		return 0, fmt.Errorf("no price for plan %q", plan)
+	}
+	return price, nil
+}
+
+// budgetPathProject returns the project ID of a /projects/{id}/... path
+func budgetPathProject(apiPath string) string {
+	parts := strings.Split(strings.TrimPrefix(apiPath, "/"), "/")
+	if len(parts) > 1 && "/"+parts[0] == projectBasePath {
+		return parts[1]
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+package packngo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testBudgetProject = "0f6a5a3e-2c1d-4c7e-9d8b-6a0d3b1e2f40"
+
+type fakeBudgetPlans struct {
+	PlanService
+}
+
+func (fakeBudgetPlans) List(*ListOptions) ([]Plan, *Response, error) {
+	return []Plan{
+		{ID: "plan-small", Slug: "c3.small.x86", Pricing: &Pricing{Hour: 0.5}},
+		{Slug: "m3.large.x86", Pricing: &Pricing{Month: 730 * 3}},
+	}, nil, nil
+}
+
+// testBudgetClient returns a client guarded by limits with a project run-rate
+// of current, and the paths of the creates that reached the API
+func testBudgetClient(t *testing.T, limits BudgetLimits, current float64) (*Client, *[]string) {
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	}))
+	t.Cleanup(ts.Close)
+
+	c, err := NewClient(WithAuth("packngo test", "token"), WithBaseURL(ts.URL+"/"), WithBudgetGuard(limits))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.budget.plans = fakeBudgetPlans{}
+	c.budget.projectHourly = func(string) (float64, error) { return current, nil }
+	return c, &sent
+}
+
+func TestBudgetGuard_Call(t *testing.T) {
+	c, sent := testBudgetClient(t, BudgetLimits{CallHourly: 10}, 0)
+
+	if _, _, err := c.Devices.Create(&DeviceCreateRequest{ProjectID: testBudgetProject, Plan: "plan-small"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Devices.Create(&DeviceCreateRequest{ProjectID: testBudgetProject, Plan: "m3.large.x86", HardwareReservationID: "next-available"}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &BatchCreateRequest{Batches: []BatchCreateDevice{
+		{DeviceCreateRequest: DeviceCreateRequest{Plan: "m3.large.x86"}, Quantity: 3},
+		{DeviceCreateRequest: DeviceCreateRequest{Plan: "c3.small.x86"}, Quantity: 4},
+	}}
+	_, _, err := c.Batches.Create(testBudgetProject, req)
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("expected BudgetExceededError, got %v", err)
+	}
+	if budgetErr.Scope != BudgetScopeCall || budgetErr.Operation != "Batches.Create" || budgetErr.Hourly != 11 || budgetErr.ProjectID != testBudgetProject {
+		t.Errorf("unexpected error %+v", budgetErr)
+	}
+
+	// spot batches are priced at their maximum bid
+	req.Batches[0].SpotInstance, req.Batches[0].SpotPriceMax = true, 1
+	if _, _, err := c.Batches.Create(testBudgetProject, req); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 3 {
+		t.Errorf("expected 3 requests to be sent, got %v", *sent)
+	}
+
+	if _, _, err := c.Devices.Create(&DeviceCreateRequest{ProjectID: testBudgetProject, Plan: "x9.unknown"}); err == nil || errors.As(err, &budgetErr) {
+		t.Errorf("expected unknown plan error, got %v", err)
+	}
+}
+
+func TestBudgetGuard_Project(t *testing.T) {
+	c, sent := testBudgetClient(t, BudgetLimits{ProjectHourly: 100, Projects: map[string]float64{testBudgetProject: 20}}, 18)
+
+	cr := &SpotMarketRequestCreateRequest{DevicesMin: 1, DevicesMax: 5, MaxBidPrice: 0.5, Parameters: SpotMarketRequestInstanceParameters{Plan: "m3.large.x86"}}
+	_, _, err := c.SpotMarketRequests.Create(cr, testBudgetProject)
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("expected BudgetExceededError, got %v", err)
+	}
+	if budgetErr.Scope != BudgetScopeProject || budgetErr.Current != 18 || budgetErr.Hourly != 2.5 || budgetErr.Limit != 20 {
+		t.Errorf("unexpected error %+v", budgetErr)
+	}
+
+	cr.DevicesMax = 4
+	if _, _, err := c.SpotMarketRequests.Create(cr, testBudgetProject); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 1 {
+		t.Errorf("expected 1 request to be sent, got %v", *sent)
+	}
+}
+
+func TestBudgetGuard_RunRateCache(t *testing.T) {
+	c, sent := testBudgetClient(t, BudgetLimits{ProjectHourly: 20, RunRateTTL: time.Minute}, 0)
+	var estimates int
+	c.budget.projectHourly = func(string) (float64, error) {
+		estimates++
+		return 18, nil
+	}
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.budget.now = func() time.Time { return now }
+
+	create := func() error {
+		_, _, err := c.Devices.Create(&DeviceCreateRequest{ProjectID: testBudgetProject, Plan: "c3.small.x86"})
+		return err
+	}
+	for i := 0; i < 4; i++ {
+		if err := create(); err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+	}
+
+	// the four creates are added to the cached run-rate of 18
+	var budgetErr *BudgetExceededError
+	if err := create(); !errors.As(err, &budgetErr) || budgetErr.Current != 20 {
+		t.Fatalf("expected BudgetExceededError at 20, got %v", err)
+	}
+	if estimates != 1 || len(*sent) != 4 {
+		t.Errorf("estimates, sent = %d, %d, want 1, 4", estimates, len(*sent))
+	}
+
+	now = now.Add(time.Minute)
+	if err := create(); err != nil {
+		t.Fatal(err)
+	}
+	if estimates != 2 {
+		t.Errorf("estimates = %d, want the run-rate to be estimated again", estimates)
+	}
+}
+
+func TestWithBudgetGuard_Unguarded(t *testing.T) {
+	c, err := NewClient(WithAuth("packngo test", "token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.checkBudget("POST", "/projects/p/devices", &DeviceCreateRequest{Plan: "x9.unknown"}); err != nil {
+		t.Errorf("expected creates of an unguarded client to pass, got %v", err)
+	}
+}
+
+func TestBudgetGuard_ConcurrentEstimates(t *testing.T) {
+	const otherProject = "0f6a5a3e-2c1d-4c7e-9d8b-6a0d3b1e2f41"
+	c, _ := testBudgetClient(t, BudgetLimits{ProjectHourly: 20}, 0)
+	estimating, release := make(chan struct{}), make(chan struct{})
+	c.budget.projectHourly = func(projectID string) (float64, error) {
+		if projectID == testBudgetProject {
+			close(estimating)
+			<-release
+		}
+		return 0, nil
+	}
+
+	slow := make(chan error)
+	go func() {
+		_, _, err := c.Devices.Create(&DeviceCreateRequest{ProjectID: testBudgetProject, Plan: "c3.small.x86"})
+		slow <- err
+	}()
+	<-estimating
+
+	// a slow estimate of one project does not hold up creates in another
+	done := make(chan error)
+	go func() {
+		_, _, err := c.Devices.Create(&DeviceCreateRequest{ProjectID: otherProject, Plan: "c3.small.x86"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("create blocked by the estimate of another project")
+	}
+
+	close(release)
+	if err := <-slow; err != nil {
+		t.Error(err)
+	}
+}
@@ -89,6 +89,10 @@ type Connection struct {
 	ServiceTokenType string               `json:"service_token_type,omitempty"`
 }
 
+func (c Connection) String() string {
+	return Stringify(c)
+}
+
 type ConnectionCreateRequest struct {
 	ContactEmail     string                 `json:"contact_email,omitempty"`
 	Description      *string                `json:"description,omitempty"`
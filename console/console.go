@@ -0,0 +1,244 @@
+package console
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/packethost/packngo"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	// DefaultPort is the SSH port of the SOS service
+	DefaultPort = 22
+
+	// DefaultTimeout limits how long Dial waits for the SSH handshake
+	DefaultTimeout = 30 * time.Second
+
+	terminalType = "xterm"
+)
+
+// ErrMarkerTimeout is returned by WaitFor when the marker is not seen in time
+var ErrMarkerTimeout = errors.New("timeout waiting for console marker")
+
+// Config holds the SSH settings of a console connection
+type Config struct {
+	// Auth are the SSH authentication methods, typically ssh.PublicKeys with
+	// a key registered with Equinix Metal
+	Auth []ssh.AuthMethod
+
+	// HostKeyCallback verifies the SOS host key, for example with
+	// golang.org/x/crypto/ssh/knownhosts. It is required.
+	HostKeyCallback ssh.HostKeyCallback
+
+	// Port defaults to DefaultPort
+	Port int
+
+	// Timeout defaults to DefaultTimeout
+	Timeout time.Duration
+
+	// Width and Height of the requested terminal. Default to 80x24.
+	Width, Height int
+}
+
+// Console is an open serial console session
+type Console struct {
+	client  *ssh.Client
+	session *ssh.Session
+	stdin   io.WriteCloser
+	stdout  io.Reader
+
+	closeOnce sync.Once
+}
+
+// Dial opens the serial console of device using its SOS host
+func Dial(device *packngo.Device, cfg *Config) (*Console, error) {
+	if device.SOS == "" {
+		return nil, fmt.Errorf("device %s has no SOS host", device.ID)
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	return DialAddr(net.JoinHostPort(device.SOS, strconv.Itoa(port)), device.ID, cfg)
+}
+
+// DialAddr opens a serial console session as user at addr
+func DialAddr(addr, user string, cfg *Config) (*Console, error) {
+	if cfg == nil || cfg.HostKeyCallback == nil {
+		return nil, fmt.Errorf("a HostKeyCallback is required")
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	width, height := cfg.Width, cfg.Height
+	if width == 0 || height == 0 {
+		width, height = 80, 24
+	}
+
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            cfg.Auth,
+		HostKeyCallback: cfg.HostKeyCallback,
+		Timeout:         timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newConsole(client, width, height)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func newConsole(client *ssh.Client, width, height int) (*Console, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	modes := ssh.TerminalModes{ssh.ECHO: 1, ssh.TTY_OP_ISPEED: 115200, ssh.TTY_OP_OSPEED: 115200}
+	if err := session.RequestPty(terminalType, height, width, modes); err != nil {
+		return nil, err
+	}
+	if err := session.Shell(); err != nil {
+		return nil, err
+	}
+	return &Console{client: client, session: session, stdin: stdin, stdout: stdout}, nil
+}
+
+// Read reads console output
+func (c *Console) Read(p []byte) (int, error) {
+	return c.stdout.Read(p)
+}
+
+// Write sends input to the console
+func (c *Console) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// Attach copies rw to the console input and console output to rw until the
+// session ends. The end of rw's input does not end the session.
+func (c *Console) Attach(rw io.ReadWriter) error {
+	errc := make(chan error, 2)
+	go func() {
+		if _, err := io.Copy(c.stdin, rw); err != nil {
+			errc <- err
+		}
+	}()
+	go func() {
+		_, err := io.Copy(rw, c.stdout)
+		errc <- err
+	}()
+	return <-errc
+}
+
+// AttachTerminal puts the terminal in into raw mode and attaches it and out
+// to the console until the session ends. The terminal is restored on return.
+func (c *Console) AttachTerminal(in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	if terminal.IsTerminal(fd) {
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = terminal.Restore(fd, state) }()
+
+		if w, h, err := terminal.GetSize(fd); err == nil {
+			_ = c.session.WindowChange(h, w)
+		}
+	}
+	return c.Attach(struct {
+		io.Reader
+		io.Writer
+	}{in, out})
+}
+
+// WaitFor reads console output until marker is seen, copying everything read
+// to capture when it is not nil. Output following the marker in the same read
+// is also copied to capture. ErrMarkerTimeout is returned if the marker is not
+// seen within timeout; the console is then closed, since the pending read can
+// not be abandoned.
+func (c *Console) WaitFor(marker string, capture io.Writer, timeout time.Duration) error {
+	found := make(chan error, 1)
+	go func() {
+		var window []byte
+		buf := make([]byte, 4096)
+		for {
+			n, err := c.stdout.Read(buf)
+			if n > 0 {
+				if capture != nil {
+					if _, werr := capture.Write(buf[:n]); werr != nil {
+						found <- werr
+						return
+					}
+				}
+				window = append(window, buf[:n]...)
+				if bytes.Contains(window, []byte(marker)) {
+					found <- nil
+					return
+				}
+				// keep enough output to match a marker split across reads
+				if keep := len(marker) - 1; len(window) > keep {
+					window = window[len(window)-keep:]
+				}
+			}
+			if err != nil {
+				found <- fmt.Errorf("console closed before %q was seen: %w", marker, err)
+				return
+			}
+		}
+	}()
+
+	select {
+	case err := <-found:
+		return err
+	case <-time.After(timeout):
+		_ = c.Close()
+		<-found
+		return ErrMarkerTimeout
+	}
+}
+
+// CaptureToFile appends console output to the file at path until marker is
+// seen or timeout elapses
+func (c *Console) CaptureToFile(path, marker string, timeout time.Duration) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	waitErr := c.WaitFor(marker, f, timeout)
+	if err := f.Close(); err != nil && waitErr == nil {
+		return err
+	}
+	return waitErr
+}
+
+// Close ends the console session and the SSH connection
+func (c *Console) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		_ = c.session.Close()
+		err = c.client.Close()
+	})
+	return err
+}
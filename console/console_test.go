@@ -0,0 +1,212 @@
+package console
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/packethost/packngo"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	testDeviceID = "9307dc37-7f39-400b-9cd2-009087434a95"
+	testBootLog  = "BIOS v1.0\r\nBooting Linux\r\nUbuntu 20.04 node-1 ttyS1\r\nnode-1 login: "
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// serveSOS runs an in-process SSH server that accepts clientKey for the test
+// device, prints testBootLog and echoes input until "exit" is received
+func serveSOS(t *testing.T, hostKey ssh.Signer, clientKey ssh.PublicKey) (port int, teardown func()) {
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() != testDeviceID || !bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, fmt.Errorf("unauthorized")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handleSOS(conn, config)
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port, func() { l.Close() }
+}
+
+func handleSOS(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		ch, requests, err := newCh.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				_ = req.Reply(req.Type == "pty-req" || req.Type == "shell" || req.Type == "window-change", nil)
+				if req.Type == "shell" {
+					go func() {
+						defer ch.Close()
+						_, _ = ch.Write([]byte(testBootLog))
+						s := bufio.NewScanner(ch)
+						for s.Scan() {
+							if s.Text() == "exit" {
+								return
+							}
+							_, _ = ch.Write([]byte("echo: " + s.Text() + "\r\n"))
+						}
+					}()
+				}
+			}
+		}()
+	}
+}
+
+func setup(t *testing.T) (*packngo.Device, *Config, func()) {
+	hostKey, clientKey := newSigner(t), newSigner(t)
+	port, teardown := serveSOS(t, hostKey, clientKey.PublicKey())
+	device := &packngo.Device{ID: testDeviceID, SOS: "127.0.0.1"}
+	cfg := &Config{
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(clientKey)},
+		HostKeyCallback: ssh.FixedHostKey(hostKey.PublicKey()),
+		Port:            port,
+		Timeout:         5 * time.Second,
+	}
+	return device, cfg, teardown
+}
+
+func Test_WaitFor(t *testing.T) {
+	device, cfg, teardown := setup(t)
+	defer teardown()
+
+	c, err := Dial(device, cfg)
+	assert.Nil(t, err)
+	defer c.Close()
+
+	capture := new(bytes.Buffer)
+	assert.Nil(t, c.WaitFor("login:", capture, 5*time.Second))
+	assert.Contains(t, capture.String(), "Booting Linux")
+}
+
+func Test_CaptureToFile(t *testing.T) {
+	device, cfg, teardown := setup(t)
+	defer teardown()
+
+	c, err := Dial(device, cfg)
+	assert.Nil(t, err)
+	defer c.Close()
+
+	dir, err := ioutil.TempDir("", "console")
+	assert.Nil(t, err)
+	logPath := filepath.Join(dir, "console.log")
+	assert.Nil(t, c.CaptureToFile(logPath, "ttyS1", 5*time.Second))
+
+	b, err := ioutil.ReadFile(logPath)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(b), "BIOS v1.0"))
+}
+
+func Test_WaitForTimeout(t *testing.T) {
+	device, cfg, teardown := setup(t)
+	defer teardown()
+
+	c, err := Dial(device, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrMarkerTimeout, c.WaitFor("never printed", nil, 100*time.Millisecond))
+}
+
+// pipeReadWriter reads from a pipe and records everything written to it
+type pipeReadWriter struct {
+	r   io.Reader
+	mu  sync.Mutex
+	out bytes.Buffer
+}
+
+func (p *pipeReadWriter) Read(b []byte) (int, error) { return p.r.Read(b) }
+
+func (p *pipeReadWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.out.Write(b)
+}
+
+func (p *pipeReadWriter) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.out.String()
+}
+
+func Test_Attach(t *testing.T) {
+	device, cfg, teardown := setup(t)
+	defer teardown()
+
+	c, err := Dial(device, cfg)
+	assert.Nil(t, err)
+	defer c.Close()
+
+	pr, pw := io.Pipe()
+	rw := &pipeReadWriter{r: pr}
+	done := make(chan error, 1)
+	go func() { done <- c.Attach(rw) }()
+
+	go func() { _, _ = pw.Write([]byte("hello\nexit\n")) }()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Attach did not return after the session ended")
+	}
+	assert.Contains(t, rw.String(), "echo: hello")
+}
+
+func Test_DialErrors(t *testing.T) {
+	_, err := Dial(&packngo.Device{ID: testDeviceID}, &Config{})
+	assert.Error(t, err)
+
+	_, err = Dial(&packngo.Device{ID: testDeviceID, SOS: "127.0.0.1"}, &Config{})
+	assert.Error(t, err)
+
+	device, cfg, teardown := setup(t)
+	defer teardown()
+	cfg.Auth = []ssh.AuthMethod{ssh.PublicKeys(newSigner(t))}
+	_, err = Dial(device, cfg)
+	assert.Error(t, err)
+}
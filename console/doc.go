@@ -0,0 +1,10 @@
+// Package console connects to the out-of-band serial console of Equinix Metal
+// devices through the Serial over SSH (SOS) service.
+//
+// The SOS host of a device is reported in packngo.Device.SOS and the SSH user
+// is the device ID. Authentication uses the SSH keys registered with the
+// Equinix Metal account.
+//
+// For more information, see
+// https://metal.equinix.com/developers/docs/resilience-recovery/serial-over-ssh/
+package console
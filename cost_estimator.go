@@ -0,0 +1,338 @@
+package packngo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CostItem kinds
+const (
+	CostKindDevice              = "device"
+	CostKindHardwareReservation = "hardware_reservation"
+	CostKindVolume              = "volume"
+	CostKindIPReservation       = "ip_reservation"
+	CostKindInterconnection     = "interconnection"
+)
+
+// CostItem pricing sources
+const (
+	CostSourceOnDemand    = "on_demand"
+	CostSourceReserved    = "reserved"
+	CostSourceSpot        = "spot"
+	CostSourceVolumePlan  = "volume_plan"
+	CostSourceRate        = "rate"
+	CostSourceUnavailable = "unavailable"
+)
+
+// CostRates prices resources whose price the API does not expose
+type CostRates struct {
+	// IPv4Hourly is the hourly price of a billed public IPv4 address
+	IPv4Hourly float64
+
+	// InterconnectionHourly is the hourly price of an interconnection by
+	// Connection.Speed in bits per second
+	InterconnectionHourly map[uint64]float64
+
+	// InterconnectionDefaultHourly prices interconnections whose speed is
+	// missing from InterconnectionHourly
+	InterconnectionDefaultHourly float64
+}
+
+// CostItem is the estimated price of a single resource
+type CostItem struct {
+	Kind    string   `json:"kind"`
+	ID      string   `json:"id"`
+	Name    string   `json:"name,omitempty"`
+	Plan    string   `json:"plan,omitempty"`
+	Metro   string   `json:"metro,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Source  string   `json:"source"`
+	Hourly  float64  `json:"hourly"`
+	Monthly float64  `json:"monthly"`
+}
+
+// CostTotal is an hourly and monthly run-rate
+type CostTotal struct {
+	Hourly  float64 `json:"hourly"`
+	Monthly float64 `json:"monthly"`
+}
+
+func (t *CostTotal) add(item CostItem) {
+	t.Hourly += item.Hourly
+	t.Monthly += item.Monthly
+}
+
+// CostEstimate is the run-rate of a project. ByTag counts the full cost of an
+// item under each of its tags, so the tag totals overlap and sum to more than
+// Total when items have several tags. Items without tags are counted under the
+// empty tag.
+type CostEstimate struct {
+	ProjectID   string               `json:"project_id"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	Items       []CostItem           `json:"items"`
+	Total       CostTotal            `json:"total"`
+	ByKind      map[string]CostTotal `json:"by_kind"`
+	ByTag       map[string]CostTotal `json:"by_tag"`
+	ByMetro     map[string]CostTotal `json:"by_metro"`
+	ByPlan      map[string]CostTotal `json:"by_plan"`
+}
+
+// CostEstimator estimates the run-rate of a project from its live
+// inventory. Devices are priced from Plan.Pricing, their hardware
+// reservation's ReservationPricing, or the current spot price. Volumes are
+// priced per GB from their plan. IP reservations and interconnections are
+// priced from Rates.
+type CostEstimator struct {
+	Devices              DeviceService
+	Volumes              VolumeService
+	ProjectIPs           ProjectIPService
+	Connections          ConnectionService
+	HardwareReservations HardwareReservationService
+	Plans                PlanService
+	SpotMarket           SpotMarketService
+
+	Rates CostRates
+
+	now func() time.Time
+}
+
+// NewCostEstimator uses the services of c
+func NewCostEstimator(c *Client, rates CostRates) *CostEstimator {
+	return &CostEstimator{
+		Devices:              c.Devices,
+		Volumes:              c.Volumes,
+		ProjectIPs:           c.ProjectIPs,
+		Connections:          c.Connections,
+		HardwareReservations: c.HardwareReservations,
+		Plans:                c.Plans,
+		SpotMarket:           c.SpotMarket,
+		Rates:                rates,
+	}
+}
+
+// Project estimates the run-rate of a project
+func (e *CostEstimator) Project(projectID string) (*CostEstimate, error) {
+	plans, _, err := e.Plans.List(nil)
+	if err != nil {
+		return nil, err
+	}
+	plansBySlug := map[string]Plan{}
+	for _, p := range plans {
+		plansBySlug[p.Slug] = p
+	}
+	lookupPlan := func(p *Plan) Plan {
+		if p == nil {
+			return Plan{}
+		}
+		if full, ok := plansBySlug[p.Slug]; ok {
+			return full
+		}
+		return *p
+	}
+
+	devices, _, err := e.Devices.List(projectID, nil)
+	if err != nil {
+		return nil, err
+	}
+	reservations, _, err := e.HardwareReservations.List(projectID, &ListOptions{Includes: []string{"facility.metro", "plan", "device"}})
+	if err != nil {
+		return nil, err
+	}
+	var spotPrices PriceMap
+	for _, d := range devices {
+		if d.SpotInstance {
+			if spotPrices, _, err = e.SpotMarket.PricesByMetro(); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	var items []CostItem
+	reservationByDevice := map[string]HardwareReservation{}
+	for _, h := range reservations {
+		if h.Device != nil {
+			reservationByDevice[h.Device.ID] = h
+			continue
+		}
+		metro := ""
+		if h.Facility.Metro != nil {
+			metro = h.Facility.Metro.Code
+		}
+		item := CostItem{Kind: CostKindHardwareReservation, ID: h.ID, Name: h.ShortID, Plan: h.Plan.Slug, Metro: metro, Source: CostSourceReserved}
+		item.Hourly = reservationHourlyPrice(lookupPlan(&h.Plan), metro, h.Intervals)
+		items = append(items, item)
+	}
+
+	for _, d := range devices {
+		plan := lookupPlan(d.Plan)
+		item := CostItem{Kind: CostKindDevice, ID: d.ID, Name: d.Hostname, Plan: plan.Slug, Metro: deviceMetro(d), Tags: d.Tags}
+		h, reserved := reservationByDevice[d.ID]
+		if !reserved && d.HardwareReservation != nil && d.HardwareReservation.ID != "" {
+			h, reserved = *d.HardwareReservation, true
+		}
+		switch {
+		case reserved:
+			item.Source = CostSourceReserved
+			item.Hourly = reservationHourlyPrice(plan, item.Metro, h.Intervals)
+		case d.SpotInstance:
+			item.Source = CostSourceSpot
+			item.Hourly = spotPrices[item.Metro][plan.Slug]
+		case plan.Pricing != nil:
+			item.Source = CostSourceOnDemand
+			item.Hourly = pricingHourly(plan.Pricing)
+		}
+		items = append(items, item)
+	}
+
+	volumes, _, err := e.Volumes.List(projectID, &ListOptions{Includes: []string{"facility.metro", "plan"}})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes {
+		plan := lookupPlan(v.Plan)
+		item := CostItem{Kind: CostKindVolume, ID: v.ID, Name: v.Name, Plan: plan.Slug}
+		if v.Facility != nil && v.Facility.Metro != nil {
+			item.Metro = v.Facility.Metro.Code
+		}
+		if plan.Pricing != nil {
+			item.Source = CostSourceVolumePlan
+			item.Hourly = float64(v.Size) * pricingHourly(plan.Pricing)
+		}
+		items = append(items, item)
+	}
+
+	ips, _, err := e.ProjectIPs.List(projectID, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if !ip.Public || ip.AddressFamily != 4 || !ip.Bill {
+			continue
+		}
+		item := CostItem{Kind: CostKindIPReservation, ID: ip.ID, Name: ip.Network + "/" + strconv.Itoa(ip.CIDR), Tags: ip.Tags, Source: CostSourceRate}
+		if ip.Metro != nil {
+			item.Metro = ip.Metro.Code
+		} else if ip.Facility != nil && ip.Facility.Metro != nil {
+			item.Metro = ip.Facility.Metro.Code
+		}
+		if ip.CIDR >= 0 && ip.CIDR <= 32 {
+			item.Hourly = float64(uint64(1)<<uint(32-ip.CIDR)) * e.Rates.IPv4Hourly
+		}
+		items = append(items, item)
+	}
+
+	connections, _, err := e.Connections.ProjectList(projectID, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range connections {
+		item := CostItem{Kind: CostKindInterconnection, ID: c.ID, Name: c.Name, Tags: c.Tags, Source: CostSourceRate}
+		if c.Metro != nil {
+			item.Metro = c.Metro.Code
+		}
+		rate, ok := e.Rates.InterconnectionHourly[c.Speed]
+		if !ok {
+			rate = e.Rates.InterconnectionDefaultHourly
+		}
+		item.Hourly = rate
+		items = append(items, item)
+	}
+
+	now := time.Now
+	if e.now != nil {
+		now = e.now
+	}
+	estimate := &CostEstimate{
+		ProjectID:   projectID,
+		GeneratedAt: now(),
+		ByKind:      map[string]CostTotal{},
+		ByTag:       map[string]CostTotal{},
+		ByMetro:     map[string]CostTotal{},
+		ByPlan:      map[string]CostTotal{},
+	}
+	for _, item := range items {
+		if item.Source == "" || (item.Hourly == 0 && item.Source != CostSourceRate) {
+			item.Source = CostSourceUnavailable
+		}
+		item.Monthly = item.Hourly * hoursPerMonth
+		estimate.Items = append(estimate.Items, item)
+		estimate.Total.add(item)
+		addCost(estimate.ByKind, item.Kind, item)
+		addCost(estimate.ByMetro, item.Metro, item)
+		addCost(estimate.ByPlan, item.Plan, item)
+		if len(item.Tags) == 0 {
+			addCost(estimate.ByTag, "", item)
+		}
+		for _, tag := range item.Tags {
+			addCost(estimate.ByTag, tag, item)
+		}
+	}
+	return estimate, nil
+}
+
+func addCost(totals map[string]CostTotal, key string, item CostItem) {
+	t := totals[key]
+	t.add(item)
+	totals[key] = t
+}
+
+func deviceMetro(d Device) string {
+	switch {
+	case d.Metro != nil:
+		return d.Metro.Code
+	case d.Facility != nil && d.Facility.Metro != nil:
+		return d.Facility.Metro.Code
+	}
+	return ""
+}
+
+// WriteJSON writes the estimate as indented JSON
+func (e *CostEstimate) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
+
+// WriteCSV writes one "item" row per resource, followed by "kind", "tag",
+// "metro" and "plan" breakdown rows and a final "total" row
+func (e *CostEstimate) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"scope", "key", "kind", "id", "name", "plan", "metro", "tags", "source", "hourly", "monthly"}); err != nil {
+		return err
+	}
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, item := range e.Items {
+		if err := cw.Write([]string{"item", "", item.Kind, item.ID, item.Name, item.Plan, item.Metro, strings.Join(item.Tags, ";"), item.Source, f(item.Hourly), f(item.Monthly)}); err != nil {
+			return err
+		}
+	}
+	breakdowns := []struct {
+		scope  string
+		totals map[string]CostTotal
+	}{{"kind", e.ByKind}, {"tag", e.ByTag}, {"metro", e.ByMetro}, {"plan", e.ByPlan}}
+	for _, b := range breakdowns {
+		keys := make([]string, 0, len(b.totals))
+		for k := range b.totals {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			t := b.totals[k]
+			if err := cw.Write([]string{b.scope, k, "", "", "", "", "", "", "", f(t.Hourly), f(t.Monthly)}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cw.Write([]string{"total", "", "", "", "", "", "", "", "", f(e.Total.Hourly), f(e.Total.Monthly)}); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,211 @@
+package packngo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeCostVolumes struct {
+	VolumeService
+	volumes []Volume
+}
+
+// List only includes the metro of volume facilities when it is requested
+func (f fakeCostVolumes) List(projectID string, opts *ListOptions) ([]Volume, *Response, error) {
+	if opts != nil && contains(opts.Includes, "facility.metro") {
+		return f.volumes, nil, nil
+	}
+	volumes := make([]Volume, len(f.volumes))
+	for i, v := range f.volumes {
+		if v.Facility != nil {
+			v.Facility = &Facility{ID: v.Facility.ID, Code: v.Facility.Code}
+		}
+		volumes[i] = v
+	}
+	return volumes, nil, nil
+}
+
+type fakeCostIPs struct {
+	ProjectIPService
+	ips []IPAddressReservation
+}
+
+func (f fakeCostIPs) List(projectID string, opts *ListOptions) ([]IPAddressReservation, *Response, error) {
+	return f.ips, nil, nil
+}
+
+type fakeCostConnections struct {
+	ConnectionService
+	connections []Connection
+}
+
+func (f fakeCostConnections) ProjectList(projectID string, opts *GetOptions) ([]Connection, *Response, error) {
+	return f.connections, nil, nil
+}
+
+func testCostEstimator() *CostEstimator {
+	sv, da := &Metro{Code: "sv"}, &Metro{Code: "da"}
+	small := Plan{Slug: "c3.small.x86"}
+	large := Plan{Slug: "m3.large.x86"}
+	ipv4 := func(id, network string, cidr int, public, bill bool) IPAddressReservation {
+		ip := IPAddressReservation{Bill: bill}
+		ip.ID, ip.Network, ip.CIDR, ip.Public, ip.AddressFamily, ip.Metro = id, network, cidr, public, 4, sv
+		return ip
+	}
+
+	return &CostEstimator{
+		Devices: &fakeSpotDevices{devices: []Device{
+			{ID: "d1", Hostname: "web1", Plan: &small, Metro: sv, Tags: []string{"web"}},
+			{ID: "d2", Hostname: "batch1", Plan: &large, Metro: sv, SpotInstance: true},
+			{ID: "d3", Hostname: "web2", Plan: &large, Facility: &Facility{Metro: da}, Tags: []string{"web", "db"}},
+			{ID: "d4", Hostname: "odd", Plan: &Plan{Slug: "x9.unknown"}, Metro: da},
+		}},
+		HardwareReservations: &fakeReportReservations{reservations: map[string][]HardwareReservation{
+			"p1": {
+				{ID: "hr-busy", Plan: small, Facility: Facility{Metro: sv}, Intervals: 12, Device: &Device{ID: "d1"}},
+				{ID: "hr-idle", ShortID: "idle", Plan: small, Facility: Facility{Metro: sv}, Intervals: 12},
+			},
+		}},
+		Volumes: fakeCostVolumes{volumes: []Volume{
+			{ID: "v1", Name: "data", Size: 10, Plan: &Plan{Slug: "storage_1"}, Facility: &Facility{Metro: sv}},
+		}},
+		ProjectIPs: fakeCostIPs{ips: []IPAddressReservation{
+			ipv4("ip1", "147.75.0.0", 30, true, true),
+			ipv4("ip2", "10.0.0.0", 25, false, true),
+			ipv4("ip3", "147.75.1.0", 31, true, false),
+		}},
+		Connections: fakeCostConnections{connections: []Connection{
+			{ID: "c1", Name: "fabric", Speed: 1000000000, Metro: sv, Tags: []string{"db"}},
+			{ID: "c2", Name: "odd", Speed: 42, Metro: da},
+		}},
+		Plans: fakePlansWithReservationPricing{plans: []Plan{
+			{
+				Slug:               "c3.small.x86",
+				Pricing:            &Pricing{Hour: 1},
+				ReservationPricing: &ReservationPricing{AnnualReservationPricing: AnnualReservationPricing{OneYear: &Pricing{Hour: 0.5}}},
+			},
+			{Slug: "m3.large.x86", Pricing: &Pricing{Hour: 3}},
+			{Slug: "storage_1", Pricing: &Pricing{Hour: 0.25}},
+		}},
+		SpotMarket: &fakeSpotMarket{prices: []PriceMap{{"sv": {"m3.large.x86": 0.75}}}},
+		Rates: CostRates{
+			IPv4Hourly:                   0.25,
+			InterconnectionHourly:        map[uint64]float64{1000000000: 2},
+			InterconnectionDefaultHourly: 1,
+		},
+		now: func() time.Time { return time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC) },
+	}
+}
+
+func TestCostEstimator_Project(t *testing.T) {
+	estimate, err := testCostEstimator().Project("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type priced struct {
+		ID, Source string
+		Hourly     float64
+	}
+	var got []priced
+	for _, item := range estimate.Items {
+		got = append(got, priced{item.ID, item.Source, item.Hourly})
+		if item.Monthly != item.Hourly*hoursPerMonth {
+			t.Errorf("%s: Monthly = %v", item.ID, item.Monthly)
+		}
+	}
+	expected := []priced{
+		{"hr-idle", CostSourceReserved, 0.5},
+		{"d1", CostSourceReserved, 0.5},
+		{"d2", CostSourceSpot, 0.75},
+		{"d3", CostSourceOnDemand, 3},
+		{"d4", CostSourceUnavailable, 0},
+		{"v1", CostSourceVolumePlan, 2.5},
+		{"ip1", CostSourceRate, 1},
+		{"c1", CostSourceRate, 2},
+		{"c2", CostSourceRate, 1},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if estimate.Total.Hourly != 11.25 {
+		t.Errorf("Total.Hourly = %v", estimate.Total.Hourly)
+	}
+	hourly := func(totals map[string]CostTotal) map[string]float64 {
+		m := map[string]float64{}
+		for k, v := range totals {
+			m[k] = v.Hourly
+		}
+		return m
+	}
+	if diff := cmp.Diff(map[string]float64{"web": 3.5, "db": 5, "": 5.75}, hourly(estimate.ByTag)); diff != "" {
+		t.Errorf("ByTag: %s", diff)
+	}
+
+	// d3 is tagged both web and db, so its 3 per hour is counted twice
+	var tagged float64
+	for _, v := range estimate.ByTag {
+		tagged += v.Hourly
+	}
+	if tagged != estimate.Total.Hourly+3 {
+		t.Errorf("ByTag sums to %v, want Total + 3", tagged)
+	}
+	if diff := cmp.Diff(map[string]float64{"sv": 7.25, "da": 4}, hourly(estimate.ByMetro)); diff != "" {
+		t.Errorf("ByMetro: %s", diff)
+	}
+	if diff := cmp.Diff(map[string]float64{"c3.small.x86": 1, "m3.large.x86": 3.75, "x9.unknown": 0, "storage_1": 2.5, "": 4}, hourly(estimate.ByPlan)); diff != "" {
+		t.Errorf("ByPlan: %s", diff)
+	}
+	if diff := cmp.Diff(map[string]float64{
+		CostKindDevice: 4.25, CostKindHardwareReservation: 0.5, CostKindVolume: 2.5, CostKindIPReservation: 1, CostKindInterconnection: 3,
+	}, hourly(estimate.ByKind)); diff != "" {
+		t.Errorf("ByKind: %s", diff)
+	}
+}
+
+func TestCostEstimate_Write(t *testing.T) {
+	estimate, err := testCostEstimator().Project("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := estimate.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[1][0] != "item" || rows[1][3] != "hr-idle" || rows[1][10] != "365" {
+		t.Errorf("unexpected item row %v", rows[1])
+	}
+	if rows[4][3] != "d3" || rows[4][7] != "web;db" {
+		t.Errorf("unexpected item row %v", rows[4])
+	}
+	last := rows[len(rows)-1]
+	if last[0] != "total" || last[9] != "11.25" {
+		t.Errorf("unexpected total row %v", last)
+	}
+	if len(rows) != 1+9+5+3+2+5+1 {
+		t.Errorf("expected 26 rows, got %d", len(rows))
+	}
+
+	buf.Reset()
+	if err := estimate.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded CostEstimate
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Items) != 9 || decoded.ByTag["db"].Hourly != 5 {
+		t.Errorf("unexpected JSON estimate %+v", decoded)
+	}
+}
@@ -0,0 +1,311 @@
+package packngo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CPR raid levels supported by the API
+const (
+	CPRRaid0  = "raid0"
+	CPRRaid1  = "raid1"
+	CPRRaid5  = "raid5"
+	CPRRaid6  = "raid6"
+	CPRRaid10 = "raid10"
+)
+
+// cprRaidMinDevices is the minimum number of member devices of each raid level
+var cprRaidMinDevices = map[string]int{
+	CPRRaid0:  2,
+	CPRRaid1:  2,
+	CPRRaid5:  3,
+	CPRRaid6:  4,
+	CPRRaid10: 4,
+}
+
+// CPR is a struct for custom partitioning and RAID. Layouts can be described
+// with the named CPR types, assembled with a CPRBuilder, or unmarshalled from
+// JSON:
+//
+//	var cpr CPR
+//	err := json.Unmarshal([]byte(cprString), &cpr)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+type CPR struct {
+	Disks       []CPRDisk       `json:"disks"`
+	Raid        []CPRRaid       `json:"raid,omitempty"`
+	Filesystems []CPRFilesystem `json:"filesystems"`
+}
+
+func (c CPR) String() string {
+	return Stringify(c)
+}
+
+// CPRDisk is a physical disk and the partitions to create on it
+type CPRDisk struct {
+	Device     string         `json:"device"`
+	WipeTable  bool           `json:"wipeTable"`
+	Partitions []CPRPartition `json:"partitions"`
+}
+
+// CPRPartition is a partition of a CPRDisk. A Size of "0" uses the remaining
+// space of the disk.
+type CPRPartition struct {
+	Label  string `json:"label"`
+	Number int    `json:"number"`
+	Size   string `json:"size"`
+}
+
+// CPRRaid is a software RAID array, such as "/dev/md/ROOT", built from
+// partitions or disks
+type CPRRaid struct {
+	Devices []string `json:"devices"`
+	Level   string   `json:"level"`
+	Name    string   `json:"name"`
+}
+
+// CPRFilesystem is a filesystem created on a partition, disk or RAID array
+type CPRFilesystem struct {
+	Mount CPRMount `json:"mount"`
+}
+
+// CPRMount describes where and how a filesystem is created and mounted
+type CPRMount struct {
+	Device string            `json:"device"`
+	Format string            `json:"format"`
+	Point  string            `json:"point"`
+	Create CPRFilesystemOpts `json:"create"`
+}
+
+// CPRFilesystemOpts are options passed to mkfs when creating a filesystem
+type CPRFilesystemOpts struct {
+	Options []string `json:"options"`
+}
+
+// CPRPartitionDevice returns the device name of partition number of disk,
+// e.g. "/dev/sda2" or "/dev/nvme0n1p2"
+func CPRPartitionDevice(disk string, number int) string {
+	if disk != "" && unicode.IsDigit(rune(disk[len(disk)-1])) {
+		return disk + "p" + strconv.Itoa(number)
+	}
+	return disk + strconv.Itoa(number)
+}
+
+// Validate checks the CPR layout for unknown RAID levels, duplicate partition
+// numbers, references to undefined devices and filesystems on missing
+// partitions. All problems found are returned as ValidationErrors.
+func (c *CPR) Validate() error {
+	var errs ValidationErrors
+
+	// devices that may be referenced by raid arrays and filesystems
+	devices := map[string]bool{}
+
+	if len(c.Disks) == 0 {
+		errs.add("disks", "at least one disk is required")
+	}
+	for i, d := range c.Disks {
+		field := fmt.Sprintf("disks[%d]", i)
+		if d.Device == "" {
+			errs.add(field+".device", "is required")
+			continue
+		}
+		if devices[d.Device] {
+			errs.add(field+".device", "%s is defined more than once", d.Device)
+		}
+		devices[d.Device] = true
+
+		numbers := map[int]bool{}
+		for j, p := range d.Partitions {
+			pfield := fmt.Sprintf("%s.partitions[%d].number", field, j)
+			if p.Number < 1 {
+				errs.add(pfield, "must be greater than zero")
+				continue
+			}
+			if numbers[p.Number] {
+				errs.add(pfield, "partition %d of %s is defined more than once", p.Number, d.Device)
+			}
+			numbers[p.Number] = true
+			devices[CPRPartitionDevice(d.Device, p.Number)] = true
+		}
+	}
+
+	members := map[string]string{}
+	for i, r := range c.Raid {
+		field := fmt.Sprintf("raid[%d]", i)
+		if r.Name == "" {
+			errs.add(field+".name", "is required")
+		}
+		min, ok := cprRaidMinDevices[r.Level]
+		if !ok {
+			errs.add(field+".level", "unknown raid level %q", r.Level)
+		} else if len(r.Devices) < min {
+			errs.add(field+".devices", "%s requires at least %d devices, got %d", r.Level, min, len(r.Devices))
+		}
+		for _, dev := range r.Devices {
+			if !devices[dev] {
+				errs.add(field+".devices", "%s is not a defined disk or partition", dev)
+			}
+			if other, ok := members[dev]; ok {
+				errs.add(field+".devices", "%s is already a member of %s", dev, other)
+			}
+			members[dev] = r.Name
+		}
+	}
+	for _, r := range c.Raid {
+		if r.Name != "" {
+			devices[r.Name] = true
+		}
+	}
+
+	points := map[string]bool{}
+	for i, f := range c.Filesystems {
+		field := fmt.Sprintf("filesystems[%d].mount", i)
+		m := f.Mount
+		if !devices[m.Device] {
+			errs.add(field+".device", "%s is not a defined disk, partition or raid array", m.Device)
+		}
+		if raid, ok := members[m.Device]; ok {
+			errs.add(field+".device", "%s is a member of %s", m.Device, raid)
+		}
+		if m.Format == "" {
+			errs.add(field+".format", "is required")
+		}
+		if m.Format != "swap" {
+			if !strings.HasPrefix(m.Point, "/") {
+				errs.add(field+".point", "%q must be an absolute path", m.Point)
+			} else if points[m.Point] {
+				errs.add(field+".point", "%s is mounted more than once", m.Point)
+			}
+			points[m.Point] = true
+		}
+	}
+
+	return errs.errOrNil()
+}
+
+// CPRLayout describes the partitions of the common layouts built by
+// CPRBuilder
+type CPRLayout struct {
+	// BIOSSize is the size of the BIOS boot partition. Defaults to "4096".
+	BIOSSize string
+
+	// SwapSize is the size of the swap partition. No swap is created when empty.
+	SwapSize string
+
+	// VarSize is the size of a separate /var partition. /var is kept on the
+	// root filesystem when empty.
+	VarSize string
+
+	// Format is the filesystem format. Defaults to "ext4".
+	Format string
+}
+
+func (l CPRLayout) withDefaults() CPRLayout {
+	if l.BIOSSize == "" {
+		l.BIOSSize = "4096"
+	}
+	if l.Format == "" {
+		l.Format = "ext4"
+	}
+	return l
+}
+
+// CPRBuilder assembles a CPR from disks, raid arrays and filesystems, or from
+// common layouts such as RAID1Root
+//
+//	cpr, err := NewCPRBuilder().
+//		RAID1Root("/dev/sda", "/dev/sdb", CPRLayout{SwapSize: "3993600", VarSize: "41943040"}).
+//		RAID10Data("/data", "/dev/sdc", "/dev/sdd", "/dev/sde", "/dev/sdf").
+//		Build()
+type CPRBuilder struct {
+	cpr CPR
+}
+
+// NewCPRBuilder starts an empty CPR layout
+func NewCPRBuilder() *CPRBuilder {
+	return &CPRBuilder{}
+}
+
+// Disk adds a disk, wiping its partition table, with the given partitions
+func (b *CPRBuilder) Disk(device string, partitions ...CPRPartition) *CPRBuilder {
+	b.cpr.Disks = append(b.cpr.Disks, CPRDisk{Device: device, WipeTable: true, Partitions: partitions})
+	return b
+}
+
+// Raid adds a raid array, e.g. "/dev/md/DATA", of the given level and devices
+func (b *CPRBuilder) Raid(name, level string, devices ...string) *CPRBuilder {
+	b.cpr.Raid = append(b.cpr.Raid, CPRRaid{Name: name, Level: level, Devices: devices})
+	return b
+}
+
+// Filesystem adds a filesystem of the given format on device mounted at point
+func (b *CPRBuilder) Filesystem(device, format, point string, options ...string) *CPRBuilder {
+	b.cpr.Filesystems = append(b.cpr.Filesystems, CPRFilesystem{Mount: CPRMount{
+		Device: device,
+		Format: format,
+		Point:  point,
+		Create: CPRFilesystemOpts{Options: options},
+	}})
+	return b
+}
+
+// RAID1Root gives two disks a BIOS boot partition each and mirrors swap, root
+// and, optionally, /var partitions across them
+func (b *CPRBuilder) RAID1Root(disk1, disk2 string, layout CPRLayout) *CPRBuilder {
+	layout = layout.withDefaults()
+
+	parts := []CPRPartition{{Label: "BIOS", Number: 1, Size: layout.BIOSSize}}
+	if layout.SwapSize != "" {
+		parts = append(parts, CPRPartition{Label: "SWAP", Number: len(parts) + 1, Size: layout.SwapSize})
+	}
+	if layout.VarSize != "" {
+		parts = append(parts, CPRPartition{Label: "VAR", Number: len(parts) + 1, Size: layout.VarSize})
+	}
+	parts = append(parts, CPRPartition{Label: "ROOT", Number: len(parts) + 1, Size: "0"})
+
+	// each disk gets its own copy, so that editing one does not edit both
+	b.Disk(disk1, append([]CPRPartition(nil), parts...)...)
+	b.Disk(disk2, append([]CPRPartition(nil), parts...)...)
+
+	for _, p := range parts[1:] {
+		name := "/dev/md/" + p.Label
+		b.Raid(name, CPRRaid1, CPRPartitionDevice(disk1, p.Number), CPRPartitionDevice(disk2, p.Number))
+		switch p.Label {
+		case "SWAP":
+			b.Filesystem(name, "swap", "none")
+		case "VAR":
+			b.Filesystem(name, layout.Format, "/var")
+		case "ROOT":
+			b.Filesystem(name, layout.Format, "/")
+		}
+	}
+	return b
+}
+
+// RAID10Data creates an ext4 RAID10 array spanning the given disks, named
+// after the mount point, e.g. "/dev/md/DATA" for "/data"
+func (b *CPRBuilder) RAID10Data(point string, disks ...string) *CPRBuilder {
+	label := strings.ToUpper(strings.Trim(strings.ReplaceAll(point, "/", "_"), "_"))
+	name := "/dev/md/" + label
+
+	members := make([]string, len(disks))
+	for i, d := range disks {
+		b.Disk(d, CPRPartition{Label: label, Number: 1, Size: "0"})
+		members[i] = CPRPartitionDevice(d, 1)
+	}
+	b.Raid(name, CPRRaid10, members...)
+	b.Filesystem(name, "ext4", point)
+	return b
+}
+
+// Build validates and returns the CPR layout
+func (b *CPRBuilder) Build() (*CPR, error) {
+	cpr := b.cpr
+	if err := cpr.Validate(); err != nil {
+		return nil, err
+	}
+	return &cpr, nil
+}
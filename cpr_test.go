@@ -0,0 +1,170 @@
+package packngo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testCPRRaid1JSON = `{
+	"disks": [
+		{"device": "/dev/sda", "wipeTable": true, "partitions": [
+			{"label": "BIOS", "number": 1, "size": "4096"},
+			{"label": "SWAP", "number": 2, "size": "3993600"},
+			{"label": "ROOT", "number": 3, "size": "0"}
+		]},
+		{"device": "/dev/sdb", "wipeTable": true, "partitions": [
+			{"label": "BIOS", "number": 1, "size": "4096"},
+			{"label": "SWAP", "number": 2, "size": "3993600"},
+			{"label": "ROOT", "number": 3, "size": "0"}
+		]}
+	],
+	"raid": [
+		{"devices": ["/dev/sda2", "/dev/sdb2"], "level": "raid1", "name": "/dev/md/SWAP"},
+		{"devices": ["/dev/sda3", "/dev/sdb3"], "level": "raid1", "name": "/dev/md/ROOT"}
+	],
+	"filesystems": [
+		{"mount": {"device": "/dev/md/SWAP", "format": "swap", "point": "none", "create": {"options": null}}},
+		{"mount": {"device": "/dev/md/ROOT", "format": "ext4", "point": "/", "create": {"options": null}}}
+	]
+}`
+
+func TestCPRBuilder_RAID1Root(t *testing.T) {
+	got, err := NewCPRBuilder().RAID1Root("/dev/sda", "/dev/sdb", CPRLayout{SwapSize: "3993600"}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := new(CPR)
+	if err := json.Unmarshal([]byte(testCPRRaid1JSON), want); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("CPRBuilder.RAID1Root() mismatch (-want +got):\n%s", diff)
+	}
+
+	got.Disks[0].Partitions[2].Size = "1"
+	if got.Disks[1].Partitions[2].Size != "0" {
+		t.Error("expected the partitions of the disks not to be shared")
+	}
+}
+
+func TestCPRBuilder_Layouts(t *testing.T) {
+	cpr, err := NewCPRBuilder().
+		RAID1Root("/dev/nvme0n1", "/dev/nvme1n1", CPRLayout{VarSize: "41943040", Format: "xfs"}).
+		RAID10Data("/data", "/dev/sdc", "/dev/sdd", "/dev/sde", "/dev/sdf").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cpr.Raid[0]; got.Name != "/dev/md/VAR" || got.Devices[0] != "/dev/nvme0n1p2" {
+		t.Errorf("unexpected /var array %s", Stringify(got))
+	}
+	if got := cpr.Raid[2]; got.Name != "/dev/md/DATA" || got.Level != CPRRaid10 || len(got.Devices) != 4 {
+		t.Errorf("unexpected data array %s", Stringify(got))
+	}
+	if got := cpr.Filesystems[1].Mount; got.Point != "/" || got.Format != "xfs" {
+		t.Errorf("unexpected root filesystem %s", Stringify(got))
+	}
+}
+
+func TestCPR_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *CPRBuilder
+		wantErr []string
+	}{
+		{
+			name:    "Valid",
+			builder: NewCPRBuilder().Disk("/dev/sda", CPRPartition{Number: 1, Size: "0"}).Filesystem("/dev/sda1", "ext4", "/"),
+		},
+		{
+			name:    "NoDisks",
+			builder: NewCPRBuilder(),
+			wantErr: []string{"disks"},
+		},
+		{
+			name:    "DuplicatePartition",
+			builder: NewCPRBuilder().Disk("/dev/sda", CPRPartition{Number: 1}, CPRPartition{Number: 1}),
+			wantErr: []string{"disks[0].partitions[1].number"},
+		},
+		{
+			name: "UnknownRaidLevel",
+			builder: NewCPRBuilder().
+				Disk("/dev/sda", CPRPartition{Number: 1}).Disk("/dev/sdb", CPRPartition{Number: 1}).
+				Raid("/dev/md/ROOT", "raid7", "/dev/sda1", "/dev/sdb1"),
+			wantErr: []string{"raid[0].level"},
+		},
+		{
+			name: "TooFewRaidDevices",
+			builder: NewCPRBuilder().
+				Disk("/dev/sda", CPRPartition{Number: 1}).Disk("/dev/sdb", CPRPartition{Number: 1}).
+				Raid("/dev/md/DATA", CPRRaid10, "/dev/sda1", "/dev/sdb1"),
+			wantErr: []string{"raid[0].devices"},
+		},
+		{
+			name: "UndefinedRaidDevice",
+			builder: NewCPRBuilder().
+				Disk("/dev/sda", CPRPartition{Number: 1}).
+				Raid("/dev/md/ROOT", CPRRaid1, "/dev/sda1", "/dev/sdb1"),
+			wantErr: []string{"raid[0].devices"},
+		},
+		{
+			name: "MissingPartition",
+			builder: NewCPRBuilder().
+				Disk("/dev/sda", CPRPartition{Number: 1}).
+				Filesystem("/dev/sda2", "ext4", "/"),
+			wantErr: []string{"filesystems[0].mount.device"},
+		},
+		{
+			name: "RaidMemberFilesystem",
+			builder: NewCPRBuilder().
+				Disk("/dev/sda", CPRPartition{Number: 1}).Disk("/dev/sdb", CPRPartition{Number: 1}).
+				Raid("/dev/md/ROOT", CPRRaid1, "/dev/sda1", "/dev/sdb1").
+				Filesystem("/dev/sda1", "ext4", "/"),
+			wantErr: []string{"filesystems[0].mount.device"},
+		},
+		{
+			name: "RelativeMountPoint",
+			builder: NewCPRBuilder().
+				Disk("/dev/sda", CPRPartition{Number: 1}).
+				Filesystem("/dev/sda1", "ext4", "var"),
+			wantErr: []string{"filesystems[0].mount.point"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("CPR.Validate() unexpected error %v", err)
+				}
+				return
+			}
+			errs, ok := err.(ValidationErrors)
+			if !ok {
+				t.Fatalf("CPR.Validate() error = %#v, want ValidationErrors", err)
+			}
+			var got []string
+			for _, e := range errs {
+				got = append(got, e.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantErr, ",") {
+				t.Errorf("CPR.Validate() fields = %v, want %v (%v)", got, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDeviceCreateRequest_ValidateStorage(t *testing.T) {
+	_, err := NewDeviceCreateRequestBuilder(testBuilderProjectID, "c3.small.x86", "ubuntu_20_04").
+		Metro("sv").
+		Storage(&CPR{Disks: []CPRDisk{{Device: "/dev/sda"}}, Raid: []CPRRaid{{Name: "/dev/md/X", Level: "raid9"}}}).
+		Build()
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 1 || errs[0].Field != "storage.raid[0].level" {
+		t.Errorf("Validate() error = %v, want error on storage.raid[0].level", err)
+	}
+}
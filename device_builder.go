@@ -0,0 +1,382 @@
+package packngo
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// OSCustomIPXE is the operating system slug used to boot devices from an
+	// iPXE script
+	OSCustomIPXE = "custom_ipxe"
+
+	// HardwareReservationNextAvailable may be used as a
+	// DeviceCreateRequest.HardwareReservationID to provision on any available
+	// reservation of the requested plan
+	HardwareReservationNextAvailable = "next-available"
+
+	// DeviceUserDataMaxSize is the largest UserData, in bytes, accepted by the API
+	DeviceUserDataMaxSize = 64 * 1024
+
+	// DeploymentTypeSpotInstance is the Plan.DeploymentTypes value of plans
+	// that can be provisioned as spot instances
+	DeploymentTypeSpotInstance = "spot_instance"
+)
+
+// DeviceCreateRequestBuilder builds a DeviceCreateRequest with a fluent
+// interface. The request is validated when it is built.
+//
+//	req, err := NewDeviceCreateRequestBuilder(projectID, "c3.small.x86", "ubuntu_20_04").
+//		Hostname("web-1").
+//		Metro("sv").
+//		Tags("web").
+//		Build()
+type DeviceCreateRequestBuilder struct {
+	req DeviceCreateRequest
+}
+
+// NewDeviceCreateRequestBuilder starts a DeviceCreateRequest for the given
+// project, plan and operating system
+func NewDeviceCreateRequestBuilder(projectID, plan, os string) *DeviceCreateRequestBuilder {
+	return &DeviceCreateRequestBuilder{req: DeviceCreateRequest{
+		ProjectID: projectID,
+		Plan:      plan,
+		OS:        os,
+	}}
+}
+
+// Hostname sets the device hostname
+func (b *DeviceCreateRequestBuilder) Hostname(hostname string) *DeviceCreateRequestBuilder {
+	b.req.Hostname = hostname
+	return b
+}
+
+// Description sets the device description
+func (b *DeviceCreateRequestBuilder) Description(description string) *DeviceCreateRequestBuilder {
+	b.req.Description = description
+	return b
+}
+
+// Metro places the device in a metro. Metro and Facilities are mutually exclusive.
+func (b *DeviceCreateRequestBuilder) Metro(metro string) *DeviceCreateRequestBuilder {
+	b.req.Metro = metro
+	return b
+}
+
+// Facilities places the device in the first available facility of the list.
+// Metro and Facilities are mutually exclusive.
+func (b *DeviceCreateRequestBuilder) Facilities(facilities ...string) *DeviceCreateRequestBuilder {
+	b.req.Facility = append(b.req.Facility, facilities...)
+	return b
+}
+
+// BillingCycle sets the billing cycle, such as "hourly"
+func (b *DeviceCreateRequestBuilder) BillingCycle(cycle string) *DeviceCreateRequestBuilder {
+	b.req.BillingCycle = cycle
+	return b
+}
+
+// UserData sets the device user data
+func (b *DeviceCreateRequestBuilder) UserData(userData string) *DeviceCreateRequestBuilder {
+	b.req.UserData = userData
+	return b
+}
+
+// CustomData sets the device custom data
+func (b *DeviceCreateRequestBuilder) CustomData(customData string) *DeviceCreateRequestBuilder {
+	b.req.CustomData = customData
+	return b
+}
+
+// Storage sets the custom partitioning and RAID layout
+func (b *DeviceCreateRequestBuilder) Storage(cpr *CPR) *DeviceCreateRequestBuilder {
+	b.req.Storage = cpr
+	return b
+}
+
+// Tags appends tags to the device
+func (b *DeviceCreateRequestBuilder) Tags(tags ...string) *DeviceCreateRequestBuilder {
+	b.req.Tags = append(b.req.Tags, tags...)
+	return b
+}
+
+// IPXE boots the device from the iPXE script at scriptURL. When always is
+// true the device will boot from the script on every boot. The operating
+// system is set to custom_ipxe.
+func (b *DeviceCreateRequestBuilder) IPXE(scriptURL string, always bool) *DeviceCreateRequestBuilder {
+	b.req.OS = OSCustomIPXE
+	b.req.IPXEScriptURL = scriptURL
+	b.req.AlwaysPXE = always
+	return b
+}
+
+// HardwareReservation provisions the device on a hardware reservation. Use
+// HardwareReservationNextAvailable to select any reservation of the plan.
+func (b *DeviceCreateRequestBuilder) HardwareReservation(id string) *DeviceCreateRequestBuilder {
+	b.req.HardwareReservationID = id
+	return b
+}
+
+// Spot provisions the device as a spot instance with the given maximum bid
+// price per hour
+func (b *DeviceCreateRequestBuilder) Spot(maxPrice float64) *DeviceCreateRequestBuilder {
+	b.req.SpotInstance = true
+	b.req.SpotPriceMax = maxPrice
+	return b
+}
+
+// TerminationTime schedules the device for deletion
+func (b *DeviceCreateRequestBuilder) TerminationTime(t time.Time) *DeviceCreateRequestBuilder {
+	b.req.TerminationTime = &Timestamp{Time: t}
+	return b
+}
+
+// PublicIPv4SubnetSize sets the CIDR size of the public IPv4 subnet
+func (b *DeviceCreateRequestBuilder) PublicIPv4SubnetSize(size int) *DeviceCreateRequestBuilder {
+	b.req.PublicIPv4SubnetSize = size
+	return b
+}
+
+// IPAddresses sets the IP addresses to be assigned to the device
+func (b *DeviceCreateRequestBuilder) IPAddresses(addresses ...IPAddressCreateRequest) *DeviceCreateRequestBuilder {
+	b.req.IPAddresses = append(b.req.IPAddresses, addresses...)
+	return b
+}
+
+// UserSSHKeys adds the SSH keys of the given collaborator user IDs
+func (b *DeviceCreateRequestBuilder) UserSSHKeys(userIDs ...string) *DeviceCreateRequestBuilder {
+	b.req.UserSSHKeys = append(b.req.UserSSHKeys, userIDs...)
+	return b
+}
+
+// ProjectSSHKeys limits the device SSH keys to the given project SSH key IDs
+func (b *DeviceCreateRequestBuilder) ProjectSSHKeys(keyIDs ...string) *DeviceCreateRequestBuilder {
+	b.req.ProjectSSHKeys = append(b.req.ProjectSSHKeys, keyIDs...)
+	return b
+}
+
+// NoSSHKeys provisions the device without any SSH keys
+func (b *DeviceCreateRequestBuilder) NoSSHKeys() *DeviceCreateRequestBuilder {
+	b.req.NoSSHKeys = true
+	return b
+}
+
+// Feature sets a plan feature, such as "tpm", to "required" or "preferred"
+func (b *DeviceCreateRequestBuilder) Feature(name, value string) *DeviceCreateRequestBuilder {
+	if b.req.Features == nil {
+		b.req.Features = map[string]string{}
+	}
+	b.req.Features[name] = value
+	return b
+}
+
+// Build validates and returns a copy of the DeviceCreateRequest
+func (b *DeviceCreateRequestBuilder) Build() (*DeviceCreateRequest, error) {
+	req := b.req
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Validate checks the DeviceCreateRequest for mistakes that the API would
+// otherwise reject. All problems found are returned as ValidationErrors.
+func (d *DeviceCreateRequest) Validate() error {
+	var errs ValidationErrors
+
+	if err := ValidateUUID(d.ProjectID); err != nil {
+		errs.add("project_id", "%s", err)
+	}
+	if d.Plan == "" {
+		errs.add("plan", "is required")
+	}
+	if d.OS == "" {
+		errs.add("operating_system", "is required")
+	}
+	if d.Hostname != "" {
+		if err := ValidateHostname(d.Hostname); err != nil {
+			errs.add("hostname", "%s", err)
+		}
+	}
+
+	// a specific hardware reservation fixes the location
+	reserved := d.HardwareReservationID != "" && ValidateUUID(d.HardwareReservationID) == nil
+	switch {
+	case d.Metro == "" && len(d.Facility) == 0 && !reserved:
+		errs.add("metro", "either metro or facility is required")
+	case d.Metro != "" && len(d.Facility) != 0:
+		errs.add("metro", "metro and facility are mutually exclusive")
+	}
+
+	if d.SpotInstance && d.SpotPriceMax <= 0 {
+		errs.add("spot_price_max", "must be greater than zero for spot instances")
+	}
+	if !d.SpotInstance && d.SpotPriceMax != 0 {
+		errs.add("spot_price_max", "is only valid for spot instances")
+	}
+
+	if d.HardwareReservationID != "" {
+		if d.HardwareReservationID != HardwareReservationNextAvailable {
+			if err := ValidateUUID(d.HardwareReservationID); err != nil {
+				errs.add("hardware_reservation_id", "must be a UUID or %q", HardwareReservationNextAvailable)
+			}
+		}
+		if d.SpotInstance {
+			errs.add("hardware_reservation_id", "spot instances can not be provisioned on hardware reservations")
+		}
+	}
+
+	if d.AlwaysPXE && d.IPXEScriptURL == "" {
+		errs.add("always_pxe", "requires ipxe_script_url")
+	}
+	if d.IPXEScriptURL != "" && d.OS != OSCustomIPXE {
+		errs.add("ipxe_script_url", "requires operating_system %q", OSCustomIPXE)
+	}
+	if d.OS == OSCustomIPXE && d.IPXEScriptURL == "" && !strings.HasPrefix(d.UserData, "#!ipxe") {
+		errs.add("ipxe_script_url", "operating_system %q requires ipxe_script_url or iPXE userdata", OSCustomIPXE)
+	}
+
+	if len(d.UserData) > DeviceUserDataMaxSize {
+		errs.add("userdata", "is %d bytes, exceeding the %d byte limit", len(d.UserData), DeviceUserDataMaxSize)
+	}
+
+	if d.Storage != nil {
+		if err := d.Storage.Validate(); err != nil {
+			for _, e := range err.(ValidationErrors) {
+				errs.add("storage."+e.Field, "%s", e.Reason)
+			}
+		}
+	}
+
+	if d.NoSSHKeys && (len(d.ProjectSSHKeys) != 0 || len(d.UserSSHKeys) != 0) {
+		errs.add("no_ssh_keys", "can not be combined with project_ssh_keys or user_ssh_keys")
+	}
+	for _, id := range d.ProjectSSHKeys {
+		if err := ValidateUUID(id); err != nil {
+			errs.add("project_ssh_keys", "%s", err)
+		}
+	}
+	for _, id := range d.UserSSHKeys {
+		if err := ValidateUUID(id); err != nil {
+			errs.add("user_ssh_keys", "%s", err)
+		}
+	}
+
+	return errs.errOrNil()
+}
+
+// ValidateCompatibility checks that the requested plan is offered in the
+// requested metro or facilities, that the operating system can be
+// provisioned on the plan, and that spot instances are supported by the plan.
+// plans and oses are typically the results of Plans.List and
+// OperatingSystems.List.
+func (d *DeviceCreateRequest) ValidateCompatibility(plans []Plan, oses []OS) error {
+	var errs ValidationErrors
+
+	var plan *Plan
+	for i := range plans {
+		if plans[i].Slug == d.Plan || plans[i].ID == d.Plan {
+			plan = &plans[i]
+			break
+		}
+	}
+	if plan == nil {
+		errs.add("plan", "%q is not a known plan", d.Plan)
+		return errs
+	}
+
+	if d.Metro != "" && len(plan.AvailableInMetros) != 0 {
+		found := false
+		for _, m := range plan.AvailableInMetros {
+			found = found || strings.EqualFold(m.Code, d.Metro)
+		}
+		if !found {
+			errs.add("metro", "plan %q is not available in metro %q", plan.Slug, d.Metro)
+		}
+	}
+
+	if len(d.Facility) != 0 && !contains(d.Facility, "any") && len(plan.AvailableIn) != 0 {
+		found := false
+		for _, f := range plan.AvailableIn {
+			found = found || contains(d.Facility, f.Code)
+		}
+		if !found {
+			errs.add("facility", "plan %q is not available in facilities %v", plan.Slug, d.Facility)
+		}
+	}
+
+	if d.SpotInstance && len(plan.DeploymentTypes) != 0 && !contains(plan.DeploymentTypes, DeploymentTypeSpotInstance) {
+		errs.add("spot_instance", "plan %q can not be provisioned as a spot instance", plan.Slug)
+	}
+
+	var os *OS
+	for i := range oses {
+		if oses[i].Slug == d.OS {
+			os = &oses[i]
+			break
+		}
+	}
+	switch {
+	case os == nil:
+		errs.add("operating_system", "%q is not a known operating system", d.OS)
+	case len(os.ProvisionableOn) != 0 && !contains(os.ProvisionableOn, plan.Slug):
+		errs.add("operating_system", "%q can not be provisioned on plan %q", d.OS, plan.Slug)
+	}
+
+	return errs.errOrNil()
+}
+
+// ValidateReservation checks that the request can be provisioned on r, the
+// hardware reservation of HardwareReservationID: the requested plan must be
+// the plan of the reservation, the reservation must be provisionable in the
+// project, and a requested metro or facility must be the reservation's.
+func (d *DeviceCreateRequest) ValidateReservation(r *HardwareReservation) error {
+	var errs ValidationErrors
+
+	if r.Plan.Slug != "" && d.Plan != r.Plan.Slug && d.Plan != r.Plan.ID {
+		errs.add("plan", "%q is not the plan %q of hardware reservation %s", d.Plan, r.Plan.Slug, r.ID)
+	}
+	if r.Project.ID != "" && r.Project.ID != d.ProjectID {
+		errs.add("hardware_reservation_id", "hardware reservation %s belongs to project %s", r.ID, r.Project.ID)
+	}
+	if !r.Provisionable {
+		errs.add("hardware_reservation_id", "hardware reservation %s is not provisionable", r.ID)
+	}
+	if d.Metro != "" && r.Facility.Metro != nil && r.Facility.Metro.Code != "" && !strings.EqualFold(d.Metro, r.Facility.Metro.Code) {
+		errs.add("metro", "hardware reservation %s is in metro %q", r.ID, r.Facility.Metro.Code)
+	}
+	if len(d.Facility) != 0 && !contains(d.Facility, "any") && r.Facility.Code != "" && !contains(d.Facility, r.Facility.Code) {
+		errs.add("facility", "hardware reservation %s is in facility %q", r.ID, r.Facility.Code)
+	}
+
+	return errs.errOrNil()
+}
+
+// ValidateWithClient runs Validate and then ValidateCompatibility against the
+// plans available to the project and all operating systems. The hardware
+// reservation of HardwareReservationID, if any, is checked with
+// ValidateReservation.
+func (d *DeviceCreateRequest) ValidateWithClient(c *Client) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	if d.HardwareReservationID != "" && d.HardwareReservationID != HardwareReservationNextAvailable {
+		opts := &GetOptions{Includes: []string{"facility.metro", "plan", "project"}}
+		r, _, err := c.HardwareReservations.Get(d.HardwareReservationID, opts)
+		if err != nil {
+			return err
+		}
+		if err := d.ValidateReservation(r); err != nil {
+			return err
+		}
+	}
+	plans, _, err := c.Plans.ProjectList(d.ProjectID, nil)
+	if err != nil {
+		return err
+	}
+	oses, _, err := c.OperatingSystems.List()
+	if err != nil {
+		return err
+	}
+	return d.ValidateCompatibility(plans, oses)
+}
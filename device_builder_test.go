@@ -0,0 +1,182 @@
+package packngo
+
+import (
+	"strings"
+	"testing"
+)
+
+const testBuilderProjectID = "93125c2a-8b78-4d4f-a3c4-7367d6b7cca8"
+
+func TestValidateHostname(t *testing.T) {
+	tests := []struct {
+		hostname string
+		wantErr  bool
+	}{
+		{"web-1", false},
+		{"web-1.example.com", false},
+		{"web-1.example.com.", false},
+		{"1web", false},
+		{"", true},
+		{"-web", true},
+		{"web-", true},
+		{"web_1", true},
+		{"web..example", true},
+		{strings.Repeat("a", 64), true},
+	}
+	for _, tt := range tests {
+		if err := ValidateHostname(tt.hostname); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateHostname(%q) error = %v, wantErr %v", tt.hostname, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDeviceCreateRequestBuilder(t *testing.T) {
+	req, err := NewDeviceCreateRequestBuilder(testBuilderProjectID, "c3.small.x86", "ubuntu_20_04").
+		Hostname("web-1").
+		Metro("sv").
+		Tags("web", "prod").
+		Spot(0.5).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Hostname != "web-1" || req.Metro != "sv" || !req.SpotInstance || req.SpotPriceMax != 0.5 || len(req.Tags) != 2 {
+		t.Errorf("unexpected request %s", req)
+	}
+}
+
+func TestDeviceCreateRequest_Validate(t *testing.T) {
+	base := func() *DeviceCreateRequestBuilder {
+		return NewDeviceCreateRequestBuilder(testBuilderProjectID, "c3.small.x86", "ubuntu_20_04").Metro("sv")
+	}
+	tests := []struct {
+		name       string
+		builder    *DeviceCreateRequestBuilder
+		wantFields []string
+	}{
+		{"Valid", base(), nil},
+		{"NoLocation", NewDeviceCreateRequestBuilder(testBuilderProjectID, "c3.small.x86", "ubuntu_20_04"), []string{"metro"}},
+		{"MetroAndFacility", base().Facilities("sv15"), []string{"metro"}},
+		{"BadProject", NewDeviceCreateRequestBuilder("nope", "c3.small.x86", "ubuntu_20_04").Metro("sv"), []string{"project_id"}},
+		{"BadHostname", base().Hostname("web_1"), []string{"hostname"}},
+		{"SpotWithoutPrice", base().Spot(0), []string{"spot_price_max"}},
+		{"SpotOnReservation", base().Spot(1).HardwareReservation(HardwareReservationNextAvailable), []string{"hardware_reservation_id"}},
+		{"BadReservation", base().HardwareReservation("abc"), []string{"hardware_reservation_id"}},
+		{"ReservationWithoutLocation", NewDeviceCreateRequestBuilder(testBuilderProjectID, "c3.small.x86", "ubuntu_20_04").HardwareReservation(testBuilderProjectID), nil},
+		{"NextAvailableWithoutLocation", NewDeviceCreateRequestBuilder(testBuilderProjectID, "c3.small.x86", "ubuntu_20_04").HardwareReservation(HardwareReservationNextAvailable), []string{"metro"}},
+		{"AlwaysPXEWithoutURL", base().IPXE("", true), []string{"always_pxe", "ipxe_script_url"}},
+		{"IPXE", base().IPXE("https://example.com/boot.ipxe", true), nil},
+		{"IPXEUserData", base().IPXE("", false).UserData("#!ipxe\nchain http://example.com"), nil},
+		{"UserDataTooLarge", base().UserData(strings.Repeat("a", DeviceUserDataMaxSize+1)), []string{"userdata"}},
+		{"NoSSHKeysWithKeys", base().NoSSHKeys().ProjectSSHKeys(testBuilderProjectID), []string{"no_ssh_keys"}},
+		{"BadProjectSSHKey", base().ProjectSSHKeys("key"), []string{"project_ssh_keys"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error %v", err)
+				}
+				return
+			}
+			errs, ok := err.(ValidationErrors)
+			if !ok {
+				t.Fatalf("Validate() error = %#v, want ValidationErrors", err)
+			}
+			var got []string
+			for _, e := range errs {
+				got = append(got, e.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("Validate() fields = %v, want %v (%v)", got, tt.wantFields, err)
+			}
+		})
+	}
+}
+
+func TestDeviceCreateRequest_ValidateCompatibility(t *testing.T) {
+	plans := []Plan{{
+		Slug:              "c3.small.x86",
+		DeploymentTypes:   []string{"on_demand"},
+		AvailableInMetros: []Metro{{Code: "sv"}, {Code: "da"}},
+		AvailableIn:       []Facility{{Code: "sv15"}},
+	}}
+	oses := []OS{
+		{Slug: "ubuntu_20_04", ProvisionableOn: []string{"c3.small.x86"}},
+		{Slug: "windows_2019", ProvisionableOn: []string{"m3.large.x86"}},
+	}
+	tests := []struct {
+		name    string
+		req     DeviceCreateRequest
+		wantErr string
+	}{
+		{"Valid", DeviceCreateRequest{Plan: "c3.small.x86", OS: "ubuntu_20_04", Metro: "SV"}, ""},
+		{"ValidFacility", DeviceCreateRequest{Plan: "c3.small.x86", OS: "ubuntu_20_04", Facility: []string{"ny5", "sv15"}}, ""},
+		{"UnknownPlan", DeviceCreateRequest{Plan: "x.large", OS: "ubuntu_20_04", Metro: "sv"}, "plan"},
+		{"WrongMetro", DeviceCreateRequest{Plan: "c3.small.x86", OS: "ubuntu_20_04", Metro: "ny"}, "metro"},
+		{"WrongFacility", DeviceCreateRequest{Plan: "c3.small.x86", OS: "ubuntu_20_04", Facility: []string{"ny5"}}, "facility"},
+		{"WrongOS", DeviceCreateRequest{Plan: "c3.small.x86", OS: "windows_2019", Metro: "sv"}, "operating_system"},
+		{"UnknownOS", DeviceCreateRequest{Plan: "c3.small.x86", OS: "beos", Metro: "sv"}, "operating_system"},
+		{"NoSpot", DeviceCreateRequest{Plan: "c3.small.x86", OS: "ubuntu_20_04", Metro: "sv", SpotInstance: true}, "spot_instance"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.ValidateCompatibility(plans, oses)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateCompatibility() unexpected error %v", err)
+				}
+				return
+			}
+			errs, ok := err.(ValidationErrors)
+			if !ok || len(errs) != 1 || errs[0].Field != tt.wantErr {
+				t.Errorf("ValidateCompatibility() error = %v, want error on %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeviceCreateRequest_ValidateReservation(t *testing.T) {
+	const reservationID = "5ee7b10a-a4a5-4b8b-9b5a-0ad1c3f3a8e2"
+	reservation := &HardwareReservation{
+		ID:            reservationID,
+		Plan:          Plan{Slug: "c3.small.x86"},
+		Project:       Project{ID: testBuilderProjectID},
+		Facility:      Facility{Code: "sv15", Metro: &Metro{Code: "sv"}},
+		Provisionable: true,
+	}
+	base := func() DeviceCreateRequest {
+		return DeviceCreateRequest{ProjectID: testBuilderProjectID, Plan: "c3.small.x86", OS: "ubuntu_20_04", HardwareReservationID: reservationID}
+	}
+	tests := []struct {
+		name       string
+		req        func(*DeviceCreateRequest)
+		wantFields []string
+	}{
+		{"Valid", func(*DeviceCreateRequest) {}, nil},
+		{"ValidMetro", func(d *DeviceCreateRequest) { d.Metro = "SV" }, nil},
+		{"WrongPlan", func(d *DeviceCreateRequest) { d.Plan = "m3.large.x86" }, []string{"plan"}},
+		{"WrongProject", func(d *DeviceCreateRequest) { d.ProjectID = reservationID }, []string{"hardware_reservation_id"}},
+		{"WrongMetro", func(d *DeviceCreateRequest) { d.Metro = "da" }, []string{"metro"}},
+		{"WrongFacility", func(d *DeviceCreateRequest) { d.Facility = []string{"da11"} }, []string{"facility"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := base()
+			tt.req(&req)
+			err := req.ValidateReservation(reservation)
+			var got []string
+			if errs, ok := err.(ValidationErrors); ok {
+				for _, e := range errs {
+					got = append(got, e.Field)
+				}
+			} else if err != nil {
+				t.Fatalf("ValidateReservation() error = %#v, want ValidationErrors", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("ValidateReservation() fields = %v, want %v (%v)", got, tt.wantFields, err)
+			}
+		})
+	}
+}
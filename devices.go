@@ -80,6 +80,9 @@ type Device struct {
 	ShortID             string                 `json:"short_id,omitempty"`
 	SwitchUUID          string                 `json:"switch_uuid,omitempty"`
 	SOS                 string                 `json:"sos,omitempty"`
+
+	// Extra holds the fields returned by the API that are not modeled above
+	Extra ExtraFields `json:"-"`
 }
 
 type NetworkInfo struct {
@@ -348,42 +351,6 @@ type IPAddressCreateRequest struct {
 	Reservations []string `json:"ip_reservations,omitempty"`
 }
 
-// CPR is a struct for custom partitioning and RAID
-// If you don't want to bother writing the struct, just write the CPR conf to
-// a string and then do
-//
-// 	var cpr CPR
-//  err := json.Unmarshal([]byte(cprString), &cpr)
-//	if err != nil {
-//		log.Fatal(err)
-//	}
-type CPR struct {
-	Disks []struct {
-		Device     string `json:"device"`
-		WipeTable  bool   `json:"wipeTable"`
-		Partitions []struct {
-			Label  string `json:"label"`
-			Number int    `json:"number"`
-			Size   string `json:"size"`
-		} `json:"partitions"`
-	} `json:"disks"`
-	Raid []struct {
-		Devices []string `json:"devices"`
-		Level   string   `json:"level"`
-		Name    string   `json:"name"`
-	} `json:"raid,omitempty"`
-	Filesystems []struct {
-		Mount struct {
-			Device string `json:"device"`
-			Format string `json:"format"`
-			Point  string `json:"point"`
-			Create struct {
-				Options []string `json:"options"`
-			} `json:"create"`
-		} `json:"mount"`
-	} `json:"filesystems"`
-}
-
 // DeviceCreateRequest type used to create an Equinix Metal device
 type DeviceCreateRequest struct {
 	Hostname              string     `json:"hostname,omitempty"`
@@ -461,7 +428,7 @@ func (d DeviceActionRequest) String() string {
 
 // DeviceServiceOp implements DeviceService
 type DeviceServiceOp struct {
-	client *Client
+	client requestDoer
 }
 
 // List returns devices on a project
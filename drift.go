@@ -0,0 +1,240 @@
+package packngo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DriftIssue kinds
+const (
+	DriftUnknownField = "unknown_field"
+	DriftTypeMismatch = "type_mismatch"
+)
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// DriftIssue is a difference between API responses and packngo types
+type DriftIssue struct {
+	// Endpoint is the method and path, with IDs replaced by {id}
+	Endpoint string `json:"endpoint"`
+
+	// Field is the JSON path of the field, e.g. devices[].plan.pricing
+	Field string `json:"field"`
+	Kind  string `json:"kind"`
+
+	// Expected is the Go type of mismatched fields
+	Expected string `json:"expected,omitempty"`
+
+	// Actual is the JSON type of the response value
+	Actual string `json:"actual"`
+
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// DriftReport collects DriftIssues of a Client configured
+// WithStrictDecoding. It is safe for concurrent use.
+type DriftReport struct {
+	mu     sync.Mutex
+	issues map[string]*DriftIssue
+}
+
+// NewDriftReport returns an empty report
+func NewDriftReport() *DriftReport {
+	return &DriftReport{issues: map[string]*DriftIssue{}}
+}
+
+// WithStrictDecoding compares every decoded JSON response with the type it is
+// decoded into and records unknown fields and type mismatches in report.
+// Type mismatches do not fail the request: the mismatched fields are left
+// unset and the rest of the response is decoded.
+func WithStrictDecoding(report *DriftReport) ClientOpt {
+	return func(c *Client) error {
+		c.drift = report
+
+		return nil
+	}
+}
+
+// Issues returns the issues sorted by endpoint and field
+func (r *DriftReport) Issues() []DriftIssue {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	issues := make([]DriftIssue, 0, len(r.issues))
+	for _, issue := range r.issues {
+		issues = append(issues, *issue)
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Endpoint != issues[j].Endpoint {
+			return issues[i].Endpoint < issues[j].Endpoint
+		}
+		if issues[i].Field != issues[j].Field {
+			return issues[i].Field < issues[j].Field
+		}
+		return issues[i].Kind < issues[j].Kind
+	})
+	return issues
+}
+
+// WriteJSON writes the issues as indented JSON
+func (r *DriftReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Issues())
+}
+
+// driftEndpoint returns the method and API path of req with IDs, numbers,
+// such as VXLANs, and metro and facility codes replaced
+func (c *Client) driftEndpoint(req *http.Request) string {
+	p := strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(c.BaseURL.Path, "/"))
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		switch {
+		case part == "":
+		case ValidateUUID(part) == nil:
+			parts[i] = "{id}"
+		case strings.Trim(part, "0123456789") == "":
+			parts[i] = "{number}"
+		case i > 0 && (parts[i-1] == "metros" || parts[i-1] == "facilities"):
+			parts[i] = "{code}"
+		}
+	}
+	return req.Method + " " + strings.Join(parts, "/")
+}
+
+// Check records the differences between a JSON response body of endpoint and
+// the type of v
+func (r *DriftReport) Check(endpoint string, body []byte, v interface{}) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	r.walk(endpoint, "", doc, reflect.TypeOf(v), now)
+}
+
+func (r *DriftReport) add(endpoint, field, kind, expected, actual string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.Join([]string{endpoint, field, kind}, "\x00")
+	issue, ok := r.issues[key]
+	if !ok {
+		issue = &DriftIssue{Endpoint: endpoint, Field: field, Kind: kind, FirstSeen: now}
+		r.issues[key] = issue
+	}
+	issue.Expected, issue.Actual = expected, actual
+	issue.Count++
+	issue.LastSeen = now
+}
+
+func (r *DriftReport) walk(endpoint, field string, doc interface{}, t reflect.Type, now time.Time) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if doc == nil || t == nil || t.Kind() == reflect.Interface {
+		return
+	}
+	// types decoding themselves are only checked when they keep unknown
+	// fields in Extra
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		if _, hasExtra := t.FieldByName("Extra"); t.Kind() != reflect.Struct || !hasExtra {
+			return
+		}
+	}
+
+	mismatch := func() {
+		r.add(endpoint, field, DriftTypeMismatch, t.String(), driftJSONType(doc), now)
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		fields := jsonFields(t)
+		for name, value := range obj {
+			path := name
+			if field != "" {
+				path = field + "." + name
+			}
+			f, ok := fields[strings.ToLower(name)]
+			switch {
+			case !ok:
+				r.add(endpoint, path, DriftUnknownField, "", driftJSONType(value), now)
+			case f.String:
+				if _, ok := value.(string); !ok && value != nil {
+					r.add(endpoint, path, DriftTypeMismatch, f.Type.String(), driftJSONType(value), now)
+				}
+			default:
+				r.walk(endpoint, path, value, f.Type, now)
+			}
+		}
+	case reflect.Map:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for _, value := range obj {
+			r.walk(endpoint, field+".*", value, t.Elem(), now)
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if _, ok := doc.(string); !ok {
+				mismatch()
+			}
+			return
+		}
+		list, ok := doc.([]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for _, value := range list {
+			r.walk(endpoint, field+"[]", value, t.Elem(), now)
+		}
+	case reflect.String:
+		if _, ok := doc.(string); !ok {
+			mismatch()
+		}
+	case reflect.Bool:
+		if _, ok := doc.(bool); !ok {
+			mismatch()
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		n, ok := doc.(float64)
+		if !ok || (t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64 && n != float64(int64(n))) {
+			mismatch()
+		}
+	}
+}
+
+// driftJSONType names the JSON type of a decoded value
+func driftJSONType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
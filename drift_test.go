@@ -0,0 +1,98 @@
+package packngo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testDriftDevice = "5d0c4e8a-3b7f-4c1a-9e2d-8f6b1a0c7e31"
+
+func TestWithStrictDecoding(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": "`+testDriftDevice+`", "hostname": "web", "locked": "yes", "future_flag": true,
+			"created_at": "2021-01-01T00:00:00Z",
+			"plan": {"slug": "c3.small.x86", "pricing": {"hour": "cheap"}},
+			"ip_addresses": [{"address": "147.75.0.1", "cidr": 31.5, "future": {}}],
+			"network_ports": [{"id": "port", "name": "bond0", "data": {"bonded": true, "mtu": 9000}}]}`)
+	}))
+	defer ts.Close()
+
+	report := NewDriftReport()
+	c, err := NewClient(WithAuth("packngo test", "token"), WithBaseURL(ts.URL+"/metal/v1/"), WithStrictDecoding(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		d, _, err := c.Devices.Get(testDriftDevice, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Hostname != "web" || d.Plan == nil || d.Plan.Slug != "c3.small.x86" || len(d.NetworkPorts) != 1 {
+			t.Errorf("expected the rest of the device to be decoded, got %+v", d)
+		}
+	}
+
+	type issue struct {
+		Field, Kind, Expected, Actual string
+		Count                         int
+	}
+	var got []issue
+	for _, i := range report.Issues() {
+		if i.Endpoint != "GET /devices/{id}" {
+			t.Errorf("unexpected endpoint %s", i.Endpoint)
+		}
+		got = append(got, issue{i.Field, i.Kind, i.Expected, i.Actual, i.Count})
+	}
+	expected := []issue{
+		{"future_flag", DriftUnknownField, "", "boolean", 2},
+		{"ip_addresses[].cidr", DriftTypeMismatch, "int", "number", 2},
+		{"ip_addresses[].future", DriftUnknownField, "", "object", 2},
+		{"locked", DriftTypeMismatch, "bool", "string", 2},
+		{"network_ports[].data.mtu", DriftUnknownField, "", "number", 2},
+		{"plan.pricing.hour", DriftTypeMismatch, "float32", "string", 2},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Error(diff)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := report.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []DriftIssue
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded) != len(expected) {
+		t.Errorf("unexpected JSON report %s, %v", buf, err)
+	}
+}
+
+func TestDriftEndpoint(t *testing.T) {
+	c, err := NewClient(WithAuth("packngo test", "token"), WithBaseURL("https://api.example.com/metal/v1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{
+		"/metal/v1/devices/" + testDriftDevice:                             "GET /devices/{id}",
+		"/metal/v1/projects/" + testDriftDevice + "/virtual-networks/1000": "GET /projects/{id}/virtual-networks/{number}",
+		"/metal/v1/locations/metros/sv":                                    "GET /locations/metros/{code}",
+		"/metal/v1/facilities/sv15":                                        "GET /facilities/{code}",
+		"/metal/v1/facilities/" + testDriftDevice:                          "GET /facilities/{id}",
+		"/metal/v1/capacity/metros":                                        "GET /capacity/metros",
+		"/metal/v1/plans":                                                  "GET /plans",
+	} {
+		req, err := http.NewRequest("GET", "https://api.example.com"+path+"?include=plan", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.driftEndpoint(req); got != expected {
+			t.Errorf("driftEndpoint(%s) = %s, want %s", path, got, expected)
+		}
+	}
+}
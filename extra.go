@@ -0,0 +1,192 @@
+package packngo
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ExtraFields holds the JSON fields of an API resource that packngo does not
+// model. They are kept when the resource is decoded and written back when it
+// is encoded, so that newer API fields survive a read-modify-write.
+type ExtraFields map[string]json.RawMessage
+
+// Decode decodes the extra field name into v. It reports whether the field
+// is present.
+func (e ExtraFields) Decode(name string, v interface{}) (bool, error) {
+	raw, ok := e[name]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// jsonField is a field of a struct as seen by encoding/json
+type jsonField struct {
+	Type reflect.Type
+
+	// String and OmitEmpty are set by the ",string" and ",omitempty" tag
+	// options
+	String    bool
+	OmitEmpty bool
+}
+
+// knownJSONFields caches the fields of struct types
+var knownJSONFields sync.Map
+
+// jsonFields returns the fields of struct type t by lowercased JSON name,
+// including the fields of embedded structs
+func jsonFields(t reflect.Type) map[string]jsonField {
+	if fields, ok := knownJSONFields.Load(t); ok {
+		return fields.(map[string]jsonField)
+	}
+
+	fields := map[string]jsonField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for embedded, field := range jsonFields(ft) {
+				if _, ok := fields[embedded]; !ok {
+					fields[embedded] = field
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		field := jsonField{Type: f.Type}
+		for _, opt := range opts[1:] {
+			field.String = field.String || opt == "string"
+			field.OmitEmpty = field.OmitEmpty || opt == "omitempty"
+		}
+		fields[strings.ToLower(name)] = field
+	}
+	knownJSONFields.Store(t, fields)
+	return fields
+}
+
+// unmarshalWithExtra decodes data into v, a pointer to a struct without
+// custom JSON methods, and returns the fields of data unknown to v
+func unmarshalWithExtra(data []byte, v interface{}) (ExtraFields, error) {
+	// type errors leave the other fields decoded
+	err := json.Unmarshal(data, v)
+	if _, ok := err.(*json.UnmarshalTypeError); err != nil && !ok {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		// null
+		return nil, err
+	}
+
+	known := jsonFields(reflect.TypeOf(v).Elem())
+	var extra ExtraFields
+	for name, raw := range fields {
+		if _, ok := known[strings.ToLower(name)]; ok {
+			continue
+		}
+		if extra == nil {
+			extra = ExtraFields{}
+		}
+		extra[name] = raw
+	}
+	return extra, err
+}
+
+// marshalWithExtra encodes v, a struct without custom JSON methods, adding
+// the extra fields not already set by v
+func marshalWithExtra(v interface{}, extra ExtraFields) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	known := jsonFields(reflect.TypeOf(v))
+	for name, raw := range extra {
+		if _, isKnown := known[strings.ToLower(name)]; !isKnown && fields[name] == nil {
+			fields[name] = raw
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON keeps unknown fields in Extra
+func (d *Device) UnmarshalJSON(data []byte) (err error) {
+	type device Device
+	d.Extra, err = unmarshalWithExtra(data, (*device)(d))
+	return err
+}
+
+// MarshalJSON writes back the fields of Extra
+func (d Device) MarshalJSON() ([]byte, error) {
+	type device Device
+	return marshalWithExtra(device(d), d.Extra)
+}
+
+// UnmarshalJSON keeps unknown fields in Extra
+func (p *Project) UnmarshalJSON(data []byte) (err error) {
+	type project Project
+	p.Extra, err = unmarshalWithExtra(data, (*project)(p))
+	return err
+}
+
+// MarshalJSON writes back the fields of Extra
+func (p Project) MarshalJSON() ([]byte, error) {
+	type project Project
+	return marshalWithExtra(project(p), p.Extra)
+}
+
+// UnmarshalJSON keeps unknown fields in Extra
+func (p *Port) UnmarshalJSON(data []byte) (err error) {
+	type port Port
+	p.Extra, err = unmarshalWithExtra(data, (*port)(p))
+	return err
+}
+
+// MarshalJSON writes back the fields of Extra
+func (p Port) MarshalJSON() ([]byte, error) {
+	type port Port
+	return marshalWithExtra(port(p), p.Extra)
+}
+
+// UnmarshalJSON keeps unknown fields in Extra
+func (i *IPAddressReservation) UnmarshalJSON(data []byte) (err error) {
+	type ipAddressReservation IPAddressReservation
+	i.Extra, err = unmarshalWithExtra(data, (*ipAddressReservation)(i))
+	return err
+}
+
+// MarshalJSON writes back the fields of Extra
+func (i IPAddressReservation) MarshalJSON() ([]byte, error) {
+	type ipAddressReservation IPAddressReservation
+	return marshalWithExtra(ipAddressReservation(i), i.Extra)
+}
+
+// UnmarshalJSON keeps unknown fields in Extra
+func (v *VirtualCircuit) UnmarshalJSON(data []byte) (err error) {
+	type virtualCircuit VirtualCircuit
+	v.Extra, err = unmarshalWithExtra(data, (*virtualCircuit)(v))
+	return err
+}
+
+// MarshalJSON writes back the fields of Extra
+func (v VirtualCircuit) MarshalJSON() ([]byte, error) {
+	type virtualCircuit VirtualCircuit
+	return marshalWithExtra(virtualCircuit(v), v.Extra)
+}
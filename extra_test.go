@@ -0,0 +1,125 @@
+package packngo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExtraFields_RoundTrip(t *testing.T) {
+	data := `{"id": "dev", "hostname": "web", "future_flag": true, "future_config": {"mode": "fast", "level": 3},
+		"project": {"id": "p1", "name": "prod", "future_quota": 10}}`
+
+	d := new(Device)
+	if err := json.Unmarshal([]byte(data), d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Hostname != "web" || d.Project == nil || d.Project.Name != "prod" {
+		t.Fatalf("unexpected device %+v", d)
+	}
+	if len(d.Extra) != 2 || len(d.Project.Extra) != 1 {
+		t.Fatalf("unexpected extra fields %v, %v", d.Extra, d.Project.Extra)
+	}
+
+	var config struct {
+		Mode  string `json:"mode"`
+		Level int    `json:"level"`
+	}
+	if ok, err := d.Extra.Decode("future_config", &config); !ok || err != nil || config.Mode != "fast" || config.Level != 3 {
+		t.Errorf("unexpected future_config %+v, %v, %v", config, ok, err)
+	}
+	var quota string
+	if ok, err := d.Project.Extra.Decode("future_quota", &quota); !ok || err == nil {
+		t.Errorf("expected a type error, got %v, %v", ok, err)
+	}
+	if ok, err := d.Extra.Decode("missing", &quota); ok || err != nil {
+		t.Errorf("expected a missing field, got %v, %v", ok, err)
+	}
+
+	// known fields win over extra fields of the same name
+	d.Hostname = "web2"
+	d.Extra["hostname"] = json.RawMessage(`"stale"`)
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["hostname"] != "web2" || out["future_flag"] != true || out["project"].(map[string]interface{})["future_quota"] != float64(10) {
+		t.Errorf("unexpected encoding %s", b)
+	}
+	if diff := cmp.Diff(map[string]interface{}{"mode": "fast", "level": float64(3)}, out["future_config"]); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestExtraFields_Embedded(t *testing.T) {
+	ip := new(IPAddressReservation)
+	if err := json.Unmarshal([]byte(`{"id": "ip", "address": "147.75.0.1", "cidr": 32, "bill": true, "future": 1}`), ip); err != nil {
+		t.Fatal(err)
+	}
+	if ip.Address != "147.75.0.1" || !ip.Bill || len(ip.Extra) != 1 || ip.Extra["future"] == nil {
+		t.Errorf("unexpected reservation %+v", ip)
+	}
+
+	port := new(Port)
+	if err := json.Unmarshal([]byte(`{"id": "port", "href": "/ports/port", "name": "bond0", "future": 1}`), port); err != nil {
+		t.Fatal(err)
+	}
+	if port.Href == nil || port.Href.Href != "/ports/port" || len(port.Extra) != 1 {
+		t.Errorf("unexpected port %+v", port)
+	}
+
+	vc := new(VirtualCircuit)
+	if err := json.Unmarshal([]byte(`{"id": "vc", "vnid": 1000, "future": 1}`), vc); err != nil {
+		t.Fatal(err)
+	}
+	if vc.VNID != 1000 || len(vc.Extra) != 1 {
+		t.Errorf("unexpected virtual circuit %+v", vc)
+	}
+
+	// structs without extra fields encode as before
+	b, err := json.Marshal(VirtualCircuit{ID: "vc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"id":"vc"}` {
+		t.Errorf("unexpected encoding %s", b)
+	}
+}
+
+func TestExtraFields_Stringify(t *testing.T) {
+	d := new(Device)
+	if err := json.Unmarshal([]byte(`{"id": "dev", "future_console": {"url": "https://console", "token": "t0k3n"}}`), d); err != nil {
+		t.Fatal(err)
+	}
+
+	s := d.String()
+	if !strings.Contains(s, `Extra:{"future_console":{"token":"**REDACTED**","url":"https://console"}}`) {
+		t.Errorf("expected Extra as redacted JSON, got %s", s)
+	}
+	if strings.Contains(s, "t0k3n") {
+		t.Errorf("expected the token to be redacted, got %s", s)
+	}
+}
+
+func TestJSONFields_Unexported(t *testing.T) {
+	type resource struct {
+		ID      string `json:"id"`
+		private string
+	}
+
+	// an API field named like an unexported field is unknown to
+	// encoding/json, so it must be kept in Extra
+	extra, err := unmarshalWithExtra([]byte(`{"id": "r", "private": "kept"}`), &resource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(extra["private"]) != `"kept"` {
+		t.Errorf("expected private in extra fields, got %v", extra)
+	}
+}
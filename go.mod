@@ -6,6 +6,7 @@ require (
 	github.com/google/go-cmp v0.5.6
 	github.com/stretchr/testify v1.8.2
 	golang.org/x/crypto v0.0.0-20200420201142-3c4aac89819a
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 go 1.16
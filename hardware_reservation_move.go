@@ -0,0 +1,206 @@
+package packngo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// HardwareReservationMoveConcurrency is the default number of concurrent
+// moves performed by ExecuteHardwareReservationMoves
+const HardwareReservationMoveConcurrency = 4
+
+// HardwareReservationMove is a planned move of one reservation
+type HardwareReservationMove struct {
+	ReservationID string
+	FromProjectID string
+	ToProjectID   string
+
+	// Reservation is the reservation as it was when the move was planned
+	Reservation *HardwareReservation
+
+	// Err explains why the reservation can not be moved
+	Err error
+}
+
+// HardwareReservationMovePlan lists the moves of a bulk move
+type HardwareReservationMovePlan struct {
+	Moves []HardwareReservationMove
+}
+
+// Invalid returns the moves that failed validation
+func (p *HardwareReservationMovePlan) Invalid() []HardwareReservationMove {
+	var invalid []HardwareReservationMove
+	for _, m := range p.Moves {
+		if m.Err != nil {
+			invalid = append(invalid, m)
+		}
+	}
+	return invalid
+}
+
+// PlanHardwareReservationMoves fetches each reservation and checks that it
+// can be moved to the project toProjectID: it must exist, have no device and
+// not already belong to the target project. Reservations failing validation
+// are kept in the plan with Err set.
+func PlanHardwareReservationMoves(s HardwareReservationService, reservationIDs []string, toProjectID string) (*HardwareReservationMovePlan, error) {
+	if err := ValidateUUID(toProjectID); err != nil {
+		return nil, err
+	}
+	plan := &HardwareReservationMovePlan{}
+	seen := map[string]bool{}
+	for _, id := range reservationIDs {
+		m := HardwareReservationMove{ReservationID: id, ToProjectID: toProjectID}
+		if seen[id] {
+			m.Err = fmt.Errorf("hardware reservation %s is listed more than once", id)
+			plan.Moves = append(plan.Moves, m)
+			continue
+		}
+		seen[id] = true
+
+		h, _, err := s.Get(id, &GetOptions{Includes: []string{"project", "device"}})
+		switch {
+		case err != nil:
+			m.Err = err
+		case h.Device != nil:
+			m.Err = fmt.Errorf("hardware reservation %s has device %s", id, h.Device.ID)
+		case h.Project.ID == toProjectID:
+			m.Err = fmt.Errorf("hardware reservation %s already belongs to project %s", id, toProjectID)
+		}
+		if h != nil {
+			m.Reservation = h
+			m.FromProjectID = h.Project.ID
+		}
+		plan.Moves = append(plan.Moves, m)
+	}
+	return plan, nil
+}
+
+// HardwareReservationMoveOpts configures ExecuteHardwareReservationMoves
+type HardwareReservationMoveOpts struct {
+	// Concurrency defaults to HardwareReservationMoveConcurrency
+	Concurrency int
+
+	// AbortOnError stops starting new moves after the first failure
+	AbortOnError bool
+
+	// Rollback moves completed reservations back to their original project
+	// when the operation is aborted by the context or by AbortOnError
+	Rollback bool
+}
+
+// HardwareReservationMoveResult is the outcome of one move
+type HardwareReservationMoveResult struct {
+	HardwareReservationMove
+
+	Moved      bool
+	RolledBack bool
+
+	// Skipped is true for invalid moves and for moves not started because
+	// the operation was aborted
+	Skipped bool
+
+	// Err is the validation error of an invalid move or the error of a
+	// failed move. It shadows HardwareReservationMove.Err.
+	Err         error
+	RollbackErr error
+}
+
+// ExecuteHardwareReservationMoves performs the valid moves of plan with
+// bounded parallelism and returns one result per planned move, in plan
+// order. The returned error is the cause of an abort: the context error, or
+// the first move error when AbortOnError is set.
+func ExecuteHardwareReservationMoves(ctx context.Context, s HardwareReservationService, plan *HardwareReservationMovePlan, opts *HardwareReservationMoveOpts) ([]HardwareReservationMoveResult, error) {
+	if opts == nil {
+		opts = &HardwareReservationMoveOpts{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = HardwareReservationMoveConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]HardwareReservationMoveResult, len(plan.Moves))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		abortErr error
+	)
+	sem := make(chan struct{}, concurrency)
+	for i, m := range plan.Moves {
+		results[i].HardwareReservationMove = m
+		if m.Err != nil {
+			results[i].Skipped, results[i].Err = true, m.Err
+			continue
+		}
+
+		started := false
+		select {
+		case sem <- struct{}{}:
+			if started = ctx.Err() == nil; !started {
+				<-sem
+			}
+		case <-ctx.Done():
+		}
+		if !started {
+			results[i].Skipped = true
+			continue
+		}
+
+		wg.Add(1)
+		go func(r *HardwareReservationMoveResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			_, _, err := s.Move(r.ReservationID, r.ToProjectID)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				r.Err = err
+				if opts.AbortOnError && abortErr == nil {
+					abortErr = err
+					cancel()
+				}
+				return
+			}
+			r.Moved = true
+		}(&results[i])
+	}
+	wg.Wait()
+
+	if abortErr == nil {
+		abortErr = ctx.Err()
+	}
+	if abortErr == nil {
+		return results, nil
+	}
+	if opts.Rollback {
+		rollbackHardwareReservationMoves(s, results, concurrency)
+	}
+	return results, abortErr
+}
+
+// rollbackHardwareReservationMoves moves completed reservations back to
+// their original project
+func rollbackHardwareReservationMoves(s HardwareReservationService, results []HardwareReservationMoveResult, concurrency int) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := range results {
+		if !results[i].Moved {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *HardwareReservationMoveResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if _, _, err := s.Move(r.ReservationID, r.FromProjectID); err != nil {
+				r.RollbackErr = err
+				return
+			}
+			r.RolledBack = true
+		}(&results[i])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,192 @@
+package packngo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testMoveFromProject = "4a2b6ab5-61b1-4b5b-8d3c-3b1a9fb1c001"
+	testMoveToProject   = "4a2b6ab5-61b1-4b5b-8d3c-3b1a9fb1c002"
+)
+
+type fakeMoveReservations struct {
+	HardwareReservationService
+
+	mu       sync.Mutex
+	project  map[string]string
+	device   map[string]bool
+	fail     map[string]bool
+	delay    time.Duration
+	inFlight int
+	peak     int
+}
+
+func (f *fakeMoveReservations) Get(id string, opts *GetOptions) (*HardwareReservation, *Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	project, ok := f.project[id]
+	if !ok {
+		return nil, nil, errBoom
+	}
+	h := &HardwareReservation{ID: id, Project: Project{ID: project}}
+	if f.device[id] {
+		h.Device = &Device{ID: "dev"}
+	}
+	return h, nil, nil
+}
+
+func (f *fakeMoveReservations) Move(id, projectID string) (*HardwareReservation, *Response, error) {
+	f.mu.Lock()
+	f.inFlight++
+	if f.inFlight > f.peak {
+		f.peak = f.inFlight
+	}
+	f.mu.Unlock()
+
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inFlight--
+	if f.fail[id] {
+		return nil, nil, errBoom
+	}
+	f.project[id] = projectID
+	return &HardwareReservation{ID: id, Project: Project{ID: projectID}}, nil, nil
+}
+
+func newFakeMoveReservations(n int) (*fakeMoveReservations, []string) {
+	f := &fakeMoveReservations{project: map[string]string{}, device: map[string]bool{}, fail: map[string]bool{}, delay: time.Millisecond}
+	var ids []string
+	for i := 0; i < n; i++ {
+		id := string(rune('a' + i))
+		f.project[id] = testMoveFromProject
+		ids = append(ids, id)
+	}
+	return f, ids
+}
+
+func TestPlanHardwareReservationMoves(t *testing.T) {
+	f, ids := newFakeMoveReservations(3)
+	f.device["b"] = true
+	f.project["c"] = testMoveToProject
+
+	plan, err := PlanHardwareReservationMoves(f, append(ids, "a", "missing"), testMoveToProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Moves) != 5 {
+		t.Fatalf("expected 5 moves, got %d", len(plan.Moves))
+	}
+	if plan.Moves[0].Err != nil || plan.Moves[0].FromProjectID != testMoveFromProject {
+		t.Errorf("expected a to be movable, got %+v", plan.Moves[0])
+	}
+	var invalid []string
+	for _, m := range plan.Invalid() {
+		invalid = append(invalid, m.ReservationID)
+	}
+	if len(invalid) != 4 || invalid[0] != "b" || invalid[1] != "c" || invalid[2] != "a" || invalid[3] != "missing" {
+		t.Errorf("unexpected invalid moves %v", invalid)
+	}
+}
+
+func TestExecuteHardwareReservationMoves(t *testing.T) {
+	f, ids := newFakeMoveReservations(8)
+	plan, err := PlanHardwareReservationMoves(f, ids, testMoveToProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ExecuteHardwareReservationMoves(context.Background(), f, plan, &HardwareReservationMoveOpts{Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if !r.Moved || r.Err != nil || f.project[r.ReservationID] != testMoveToProject {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+	if f.peak > 3 {
+		t.Errorf("expected at most 3 concurrent moves, got %d", f.peak)
+	}
+}
+
+func TestExecuteHardwareReservationMoves_Invalid(t *testing.T) {
+	f, ids := newFakeMoveReservations(2)
+	f.device["b"] = true
+	plan, err := PlanHardwareReservationMoves(f, ids, testMoveToProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ExecuteHardwareReservationMoves(context.Background(), f, plan, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Moved || results[0].Err != nil {
+		t.Errorf("unexpected result %+v", results[0])
+	}
+	if b := results[1]; !b.Skipped || b.Moved || b.Err == nil || b.Err != plan.Moves[1].Err {
+		t.Errorf("expected b to be skipped with its validation error, got %+v", b)
+	}
+	if f.project["b"] != testMoveFromProject {
+		t.Errorf("expected b not to be moved")
+	}
+}
+
+func TestExecuteHardwareReservationMoves_Rollback(t *testing.T) {
+	f, ids := newFakeMoveReservations(6)
+	plan, err := PlanHardwareReservationMoves(f, ids, testMoveToProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.fail["b"] = true
+
+	results, err := ExecuteHardwareReservationMoves(context.Background(), f, plan, &HardwareReservationMoveOpts{
+		Concurrency:  1,
+		AbortOnError: true,
+		Rollback:     true,
+	})
+	if err != errBoom {
+		t.Fatalf("expected errBoom, got %v", err)
+	}
+	if !results[0].Moved || !results[0].RolledBack {
+		t.Errorf("expected a to be rolled back, got %+v", results[0])
+	}
+	if results[1].Err != errBoom || results[1].Moved {
+		t.Errorf("expected b to fail, got %+v", results[1])
+	}
+	for _, r := range results[2:] {
+		if !r.Skipped || r.Moved {
+			t.Errorf("expected %s to be skipped, got %+v", r.ReservationID, r)
+		}
+	}
+	for id, project := range f.project {
+		if project != testMoveFromProject {
+			t.Errorf("reservation %s left in %s", id, project)
+		}
+	}
+}
+
+func TestExecuteHardwareReservationMoves_Canceled(t *testing.T) {
+	f, ids := newFakeMoveReservations(3)
+	plan, err := PlanHardwareReservationMoves(f, ids, testMoveToProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := ExecuteHardwareReservationMoves(ctx, f, plan, nil)
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	for _, r := range results {
+		if !r.Skipped {
+			t.Errorf("expected %s to be skipped, got %+v", r.ReservationID, r)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package packngo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hoursPerMonth converts monthly to hourly prices
+const hoursPerMonth = 730
+
+// HardwareReservationUsage is the utilization of a single reservation
+type HardwareReservationUsage struct {
+	ID        string `json:"id"`
+	ShortID   string `json:"short_id,omitempty"`
+	ProjectID string `json:"project_id"`
+	Plan      string `json:"plan"`
+	Facility  string `json:"facility"`
+	Metro     string `json:"metro,omitempty"`
+	Spare     bool   `json:"spare,omitempty"`
+
+	DeviceID       string `json:"device_id,omitempty"`
+	DeviceHostname string `json:"device_hostname,omitempty"`
+
+	// IdleSince is the latest event of the last device provisioned on the
+	// reservation, e.g. its deprovisioning, or the creation of the
+	// reservation when no event relates a device to it. It is nil for
+	// reservations in use.
+	IdleSince *time.Time `json:"idle_since,omitempty"`
+	IdleHours float64    `json:"idle_hours"`
+
+	CurrentPeriod    int       `json:"current_period"`
+	Intervals        int       `json:"intervals"`
+	PeriodsRemaining int       `json:"periods_remaining"`
+	TermEnd          time.Time `json:"term_end"`
+
+	// HourlyPrice is the reserved price of the plan in the metro, falling
+	// back to the plan's on-demand price
+	HourlyPrice float64 `json:"hourly_price"`
+
+	// IdleCost is zero for spare reservations
+	IdleCost float64 `json:"idle_cost"`
+}
+
+// InUse reports whether a device is provisioned on the reservation
+func (u HardwareReservationUsage) InUse() bool {
+	return u.DeviceID != ""
+}
+
+// HardwareReservationReport summarizes the reservations of one or more
+// projects
+type HardwareReservationReport struct {
+	GeneratedAt  time.Time                  `json:"generated_at"`
+	Reservations []HardwareReservationUsage `json:"reservations"`
+	InUse        int                        `json:"in_use"`
+
+	// Idle and IdleCost cover the unused reservations that are not spares
+	Idle     int     `json:"idle"`
+	IdleCost float64 `json:"idle_cost"`
+
+	// Spares is the number of unused spare reservations
+	Spares int `json:"spares"`
+}
+
+// HardwareReservationReporter builds HardwareReservationReports. Idle time is
+// derived from the project events of the last device provisioned on each
+// reservation.
+type HardwareReservationReporter struct {
+	Reservations  HardwareReservationService
+	Projects      ProjectService
+	Organizations OrganizationService
+	Plans         PlanService
+
+	now func() time.Time
+}
+
+// NewHardwareReservationReporter uses the services of c
+func NewHardwareReservationReporter(c *Client) *HardwareReservationReporter {
+	return &HardwareReservationReporter{
+		Reservations:  c.HardwareReservations,
+		Projects:      c.Projects,
+		Organizations: c.Organizations,
+		Plans:         c.Plans,
+	}
+}
+
+func (r *HardwareReservationReporter) timeNow() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// Project reports on the reservations of a project
+func (r *HardwareReservationReporter) Project(projectID string) (*HardwareReservationReport, error) {
+	return r.report([]string{projectID})
+}
+
+// Organization reports on the reservations of every project of an
+// organization
+func (r *HardwareReservationReporter) Organization(organizationID string) (*HardwareReservationReport, error) {
+	org, _, err := r.Organizations.Get(organizationID, &GetOptions{Includes: []string{"projects"}})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(org.Projects))
+	for i, p := range org.Projects {
+		ids[i] = p.ID
+	}
+	return r.report(ids)
+}
+
+func (r *HardwareReservationReporter) report(projectIDs []string) (*HardwareReservationReport, error) {
+	plans, _, err := r.Plans.List(nil)
+	if err != nil {
+		return nil, err
+	}
+	plansBySlug := map[string]Plan{}
+	for _, p := range plans {
+		plansBySlug[p.Slug] = p
+	}
+
+	report := &HardwareReservationReport{GeneratedAt: r.timeNow()}
+	for _, projectID := range projectIDs {
+		reservations, _, err := r.Reservations.List(projectID, &ListOptions{Includes: []string{"facility.metro", "plan", "device"}})
+		if err != nil {
+			return nil, err
+		}
+		if len(reservations) == 0 {
+			continue
+		}
+		// without a page in the options, every page of events is read
+		events, _, err := r.Projects.ListEvents(projectID, nil)
+		if err != nil {
+			return nil, err
+		}
+		idleSince := reservationIdleSince(events)
+
+		for _, h := range reservations {
+			u := hardwareReservationUsage(h, projectID, plansBySlug[h.Plan.Slug])
+			if u.InUse() {
+				report.InUse++
+				report.Reservations = append(report.Reservations, u)
+				continue
+			}
+
+			since := h.CreatedAt.Time
+			if t, ok := idleSince[h.ID]; ok && t.After(since) {
+				since = t
+			}
+			u.IdleSince = &since
+			u.IdleHours = report.GeneratedAt.Sub(since).Hours()
+			if u.Spare {
+				report.Spares++
+			} else {
+				u.IdleCost = u.IdleHours * u.HourlyPrice
+				report.Idle++
+				report.IdleCost += u.IdleCost
+			}
+			report.Reservations = append(report.Reservations, u)
+		}
+	}
+
+	sort.SliceStable(report.Reservations, func(i, j int) bool {
+		return report.Reservations[i].IdleCost > report.Reservations[j].IdleCost
+	})
+	return report, nil
+}
+
+// reservationIdleSince returns the time of the latest event of the last device
+// related to each hardware reservation, keyed by reservation ID. A device is
+// related to a reservation by an event referencing both.
+func reservationIdleSince(events []Event) map[string]time.Time {
+	type deviceEvent struct {
+		id   string
+		when time.Time
+	}
+	lastDevice := map[string]deviceEvent{}
+	lastEvent := map[string]time.Time{}
+	for _, e := range events {
+		if e.CreatedAt == nil {
+			continue
+		}
+		t := e.CreatedAt.Time
+		devices := eventRelationships(e, deviceBasePath)
+		for _, id := range devices {
+			if t.After(lastEvent[id]) {
+				lastEvent[id] = t
+			}
+		}
+		if len(devices) == 0 {
+			continue
+		}
+		for _, id := range eventRelationships(e, hardwareReservationBasePath) {
+			if t.After(lastDevice[id].when) {
+				lastDevice[id] = deviceEvent{id: devices[0], when: t}
+			}
+		}
+	}
+
+	since := map[string]time.Time{}
+	for id, d := range lastDevice {
+		since[id] = lastEvent[d.id]
+	}
+	return since
+}
+
+// eventRelationships returns the IDs of the resources under basePath that e
+// relates to
+func eventRelationships(e Event, basePath string) (ids []string) {
+	for _, rel := range e.Relationships {
+		dir, id := path.Split(rel.Href)
+		if path.Base(dir) == strings.TrimPrefix(basePath, "/") {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func hardwareReservationUsage(h HardwareReservation, projectID string, plan Plan) HardwareReservationUsage {
+	u := HardwareReservationUsage{
+		ID:               h.ID,
+		ShortID:          h.ShortID,
+		ProjectID:        projectID,
+		Plan:             h.Plan.Slug,
+		Facility:         h.Facility.Code,
+		Spare:            h.Spare,
+		CurrentPeriod:    h.CurrentPeriod,
+		Intervals:        h.Intervals,
+		PeriodsRemaining: h.PeriodsRemaining(),
+		TermEnd:          h.TermEnd(),
+	}
+	if h.Facility.Metro != nil {
+		u.Metro = h.Facility.Metro.Code
+	}
+	if h.Device != nil {
+		u.DeviceID, u.DeviceHostname = h.Device.ID, h.Device.Hostname
+	}
+	if plan.Slug == "" {
+		plan = h.Plan
+	}
+	u.HourlyPrice = reservationHourlyPrice(plan, u.Metro, h.Intervals)
+	return u
+}
+
+// pricingHourly returns the hourly price, derived from the monthly price when
+// only that is set
+func pricingHourly(p *Pricing) float64 {
+	switch {
+	case p == nil:
+		return 0
+	case p.Hour > 0:
+		return float64(p.Hour)
+	default:
+		return float64(p.Month) / hoursPerMonth
+	}
+}
+
+// reservationHourlyPrice returns the hourly reserved price of plan for a term
+// of the given number of monthly intervals
+func reservationHourlyPrice(plan Plan, metro string, intervals int) float64 {
+	term := func(a AnnualReservationPricing) *Pricing {
+		if intervals > 12 && a.ThreeYear != nil {
+			return a.ThreeYear
+		}
+		return a.OneYear
+	}
+
+	if rp := plan.ReservationPricing; rp != nil {
+		if a, ok := rp.Metros[metro]; ok {
+			if price := pricingHourly(term(a)); price > 0 {
+				return price
+			}
+		}
+		if price := pricingHourly(term(rp.AnnualReservationPricing)); price > 0 {
+			return price
+		}
+	}
+	return pricingHourly(plan.Pricing)
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *HardwareReservationReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes one row per reservation
+func (r *HardwareReservationReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "short_id", "project_id", "plan", "facility", "metro", "spare", "device_id", "device_hostname", "idle_since", "idle_hours", "current_period", "intervals", "periods_remaining", "term_end", "hourly_price", "idle_cost"}); err != nil {
+		return err
+	}
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, u := range r.Reservations {
+		idleSince := ""
+		if u.IdleSince != nil {
+			idleSince = u.IdleSince.UTC().Format(time.RFC3339)
+		}
+		if err := cw.Write([]string{
+			u.ID, u.ShortID, u.ProjectID, u.Plan, u.Facility, u.Metro, strconv.FormatBool(u.Spare),
+			u.DeviceID, u.DeviceHostname, idleSince, f(u.IdleHours),
+			strconv.Itoa(u.CurrentPeriod), strconv.Itoa(u.Intervals), strconv.Itoa(u.PeriodsRemaining),
+			u.TermEnd.UTC().Format(time.RFC3339), f(u.HourlyPrice), f(u.IdleCost),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
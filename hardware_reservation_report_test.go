@@ -0,0 +1,176 @@
+package packngo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type fakeReportProjects struct {
+	ProjectService
+	events map[string][]Event
+}
+
+func (f *fakeReportProjects) ListEvents(projectID string, opts *ListOptions) ([]Event, *Response, error) {
+	return f.events[projectID], nil, nil
+}
+
+type fakeReportOrganizations struct {
+	OrganizationService
+}
+
+func (fakeReportOrganizations) Get(id string, opts *GetOptions) (*Organization, *Response, error) {
+	return &Organization{ID: id, Projects: []Project{{ID: "p1"}, {ID: "p2"}}}, nil, nil
+}
+
+type fakeReportReservations struct {
+	HardwareReservationService
+	reservations map[string][]HardwareReservation
+}
+
+func (f *fakeReportReservations) List(projectID string, opts *ListOptions) ([]HardwareReservation, *Response, error) {
+	return f.reservations[projectID], nil, nil
+}
+
+func testHardwareReservationReporter() *HardwareReservationReporter {
+	created := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	deleted := time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC)
+	sv := Facility{Code: "sv15", Metro: &Metro{Code: "sv"}}
+	small := Plan{Slug: "c3.small.x86"}
+
+	return &HardwareReservationReporter{
+		Reservations: &fakeReportReservations{reservations: map[string][]HardwareReservation{
+			"p1": {
+				{ID: "busy", Plan: small, Facility: sv, Intervals: 12, CurrentPeriod: 3, CreatedAt: Timestamp{created}, Device: &Device{ID: "dev", Hostname: "web"}},
+				{ID: "recent", Plan: small, Facility: sv, Intervals: 12, CurrentPeriod: 3, CreatedAt: Timestamp{created}, Provisionable: true},
+			},
+			"p2": {
+				{ID: "never", Plan: small, Facility: sv, Intervals: 36, CurrentPeriod: 3, CreatedAt: Timestamp{created}, Provisionable: true},
+				{ID: "spare", Plan: small, Facility: sv, Intervals: 12, CurrentPeriod: 3, CreatedAt: Timestamp{created}, Provisionable: true, Spare: true},
+			},
+		}},
+		Projects: &fakeReportProjects{events: map[string][]Event{
+			"p1": {
+				{Type: "hardware-reservation.updated", CreatedAt: &Timestamp{deleted.Add(12 * time.Hour)}, Relationships: []Href{{Href: "/hardware-reservations/recent"}}},
+				{Type: "instance.deleted", CreatedAt: &Timestamp{deleted}, Relationships: []Href{{Href: "/devices/old"}}},
+				{Type: "instance.created", CreatedAt: &Timestamp{deleted.Add(-time.Hour)}, Relationships: []Href{{Href: "/devices/old"}, {Href: "/hardware-reservations/recent"}}},
+				{Type: "instance.deleted", CreatedAt: &Timestamp{deleted.Add(-2 * time.Hour)}, Relationships: []Href{{Href: "/devices/older"}}},
+				{Type: "instance.created", CreatedAt: &Timestamp{created}, Relationships: []Href{{Href: "/devices/older"}, {Href: "/hardware-reservations/recent"}}},
+			},
+		}},
+		Organizations: fakeReportOrganizations{},
+		Plans: fakePlansWithReservationPricing{plans: []Plan{{
+			Slug:    "c3.small.x86",
+			Pricing: &Pricing{Hour: 1},
+			ReservationPricing: &ReservationPricing{
+				AnnualReservationPricing: AnnualReservationPricing{OneYear: &Pricing{Hour: 0.5}, ThreeYear: &Pricing{Month: 219}},
+				Metros:                   MetroPricing{"da": {OneYear: &Pricing{Hour: 0.4}}},
+			},
+		}}},
+		now: func() time.Time { return now },
+	}
+}
+
+type fakePlansWithReservationPricing struct {
+	PlanService
+	plans []Plan
+}
+
+func (f fakePlansWithReservationPricing) List(*ListOptions) ([]Plan, *Response, error) {
+	return f.plans, nil, nil
+}
+
+func TestHardwareReservationReporter_Organization(t *testing.T) {
+	report, err := testHardwareReservationReporter().Organization("org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.InUse != 1 || report.Idle != 2 || report.Spares != 1 || len(report.Reservations) != 4 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	// "never" has been idle since creation at the three year rate of 219/730
+	never := report.Reservations[0]
+	if never.ID != "never" || never.IdleHours != 59*24 || never.HourlyPrice != 0.3 || never.PeriodsRemaining != 34 {
+		t.Errorf("unexpected usage %+v", never)
+	}
+	if never.ProjectID != "p2" || never.TermEnd != time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("unexpected term %+v", never)
+	}
+
+	// "recent" has been idle since the deletion of its last device "old"
+	recent := report.Reservations[1]
+	if recent.ID != "recent" || recent.IdleHours != 24 || recent.HourlyPrice != 0.5 || recent.IdleCost != 12 {
+		t.Errorf("unexpected usage %+v", recent)
+	}
+
+	busy := report.Reservations[2]
+	if busy.ID != "busy" || !busy.InUse() || busy.IdleSince != nil || busy.IdleCost != 0 || busy.DeviceHostname != "web" {
+		t.Errorf("unexpected usage %+v", busy)
+	}
+
+	// "spare" is idle but not billed
+	spare := report.Reservations[3]
+	if spare.ID != "spare" || spare.IdleHours != 59*24 || spare.IdleCost != 0 {
+		t.Errorf("unexpected usage %+v", spare)
+	}
+	if report.IdleCost != never.IdleCost+recent.IdleCost {
+		t.Errorf("IdleCost = %v", report.IdleCost)
+	}
+}
+
+func TestHardwareReservationReport_Write(t *testing.T) {
+	report, err := testHardwareReservationReporter().Project("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := report.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1][0] != "recent" || rows[1][9] != "2021-02-28T00:00:00Z" || rows[1][16] != "12" {
+		t.Errorf("unexpected CSV %v", rows)
+	}
+
+	buf.Reset()
+	if err := report.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded HardwareReservationReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Idle != 1 || decoded.Reservations[0].IdleCost != 12 {
+		t.Errorf("unexpected JSON report %+v", decoded)
+	}
+}
+
+func TestProjectListEvents_AllPages(t *testing.T) {
+	pages := []string{
+		`{"events": [{"id": "e1"}], "meta": {"current_page": 1, "last_page": 2, "next": {"href": "/projects/p/events?page=2"}}}`,
+		`{"events": [{"id": "e2"}], "meta": {"current_page": 2, "last_page": 2}}`,
+	}
+	var calls int
+	s := &ProjectServiceOp{client: &MockClient{
+		fnDoRequest: func(method, path string, body, v interface{}) (*Response, error) {
+			calls++
+			return nil, json.Unmarshal([]byte(pages[calls-1]), v)
+		},
+	}}
+
+	events, _, err := s.ListEvents("00000000-0000-0000-0000-000000000000", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || len(events) != 2 || events[1].ID != "e2" {
+		t.Errorf("ListEvents read %d pages, got %+v", calls, events)
+	}
+}
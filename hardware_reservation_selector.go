@@ -0,0 +1,203 @@
+package packngo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoHardwareReservation is returned when no reservation matches a
+// HardwareReservationFilter
+var ErrNoHardwareReservation = errors.New("no matching hardware reservation is available")
+
+// CurrentPeriodEnd returns the end of the current billing period. Periods are
+// assumed to be monthly, starting at CreatedAt, with CurrentPeriod counting
+// from one.
+func (h HardwareReservation) CurrentPeriodEnd() time.Time {
+	return h.CreatedAt.AddDate(0, h.CurrentPeriod, 0)
+}
+
+// TermEnd returns the end of the last of the reservation's Intervals
+func (h HardwareReservation) TermEnd() time.Time {
+	return h.CreatedAt.AddDate(0, h.Intervals, 0)
+}
+
+// PeriodsRemaining returns the number of billing periods left in the term,
+// including the current one
+func (h HardwareReservation) PeriodsRemaining() int {
+	if remaining := h.Intervals - h.CurrentPeriod + 1; remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// HardwareReservationFilter selects reservations. Empty fields match any
+// reservation. Spare and unprovisionable reservations are excluded unless
+// explicitly included.
+type HardwareReservationFilter struct {
+	Plan     string
+	Metro    string
+	Facility string
+
+	IncludeSpare           bool
+	IncludeUnprovisionable bool
+}
+
+// Match reports whether h satisfies the filter
+func (f HardwareReservationFilter) Match(h HardwareReservation) bool {
+	if f.Plan != "" && h.Plan.Slug != f.Plan && h.Plan.ID != f.Plan {
+		return false
+	}
+	if f.Facility != "" && !strings.EqualFold(h.Facility.Code, f.Facility) && h.Facility.ID != f.Facility {
+		return false
+	}
+	if f.Metro != "" && (h.Facility.Metro == nil || !strings.EqualFold(h.Facility.Metro.Code, f.Metro)) {
+		return false
+	}
+	if h.Spare && !f.IncludeSpare {
+		return false
+	}
+	if (!h.Provisionable || h.Device != nil) && !f.IncludeUnprovisionable {
+		return false
+	}
+	return true
+}
+
+// HardwareReservationSelector picks reservations for new devices. Selected
+// reservations are claimed locally until released, so that concurrent
+// creates sharing a selector never pick the same reservation.
+//
+//	sel := NewHardwareReservationSelector(c.HardwareReservations, projectID)
+//	req := &DeviceCreateRequest{Plan: "c3.small.x86", Metro: "sv", HardwareReservationID: HardwareReservationNextAvailable, ...}
+//	device, _, err := sel.CreateDevice(c.Devices, req)
+type HardwareReservationSelector struct {
+	service   HardwareReservationService
+	projectID string
+
+	mu      sync.Mutex
+	claimed map[string]bool
+}
+
+// NewHardwareReservationSelector selects among the reservations of projectID
+func NewHardwareReservationSelector(service HardwareReservationService, projectID string) *HardwareReservationSelector {
+	return &HardwareReservationSelector{service: service, projectID: projectID, claimed: map[string]bool{}}
+}
+
+// Candidates lists the unclaimed reservations matching filter, preferring
+// those whose current period ends first and then those with the fewest
+// periods left in their term
+func (s *HardwareReservationSelector) Candidates(filter HardwareReservationFilter) ([]HardwareReservation, error) {
+	reservations, _, err := s.service.List(s.projectID, &ListOptions{Includes: []string{"facility.metro", "plan", "device"}})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var candidates []HardwareReservation
+	for _, h := range reservations {
+		if !s.claimed[h.ID] && filter.Match(h) {
+			candidates = append(candidates, h)
+		}
+	}
+	sortHardwareReservations(candidates)
+	return candidates, nil
+}
+
+func sortHardwareReservations(hs []HardwareReservation) {
+	sort.SliceStable(hs, func(i, j int) bool {
+		if ei, ej := hs[i].CurrentPeriodEnd(), hs[j].CurrentPeriodEnd(); !ei.Equal(ej) {
+			return ei.Before(ej)
+		}
+		if pi, pj := hs[i].PeriodsRemaining(), hs[j].PeriodsRemaining(); pi != pj {
+			return pi < pj
+		}
+		return hs[i].ID < hs[j].ID
+	})
+}
+
+// Claim selects and claims the preferred reservation matching filter. It
+// returns ErrNoHardwareReservation when there is none.
+func (s *HardwareReservationSelector) Claim(filter HardwareReservationFilter) (*HardwareReservation, error) {
+	candidates, err := s.Candidates(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range candidates {
+		// another goroutine may have claimed it since Candidates returned
+		if !s.claimed[h.ID] {
+			s.claimed[h.ID] = true
+			return &h, nil
+		}
+	}
+	return nil, ErrNoHardwareReservation
+}
+
+// ClaimID claims a specific reservation, failing if it is already claimed
+func (s *HardwareReservationSelector) ClaimID(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.claimed[id] {
+		return fmt.Errorf("hardware reservation %s is already claimed", id)
+	}
+	s.claimed[id] = true
+	return nil
+}
+
+// Release returns a claimed reservation to the pool, e.g. after a failed
+// device create
+func (s *HardwareReservationSelector) Release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claimed, id)
+}
+
+// Apply claims a reservation for req. When HardwareReservationID is
+// HardwareReservationNextAvailable, the preferred reservation matching the
+// plan, metro and single facility of req is selected and its ID set on req.
+// Any other ID is claimed as is. The returned function releases the claim.
+func (s *HardwareReservationSelector) Apply(req *DeviceCreateRequest) (release func(), err error) {
+	switch req.HardwareReservationID {
+	case "":
+		return func() {}, nil
+	case HardwareReservationNextAvailable:
+		filter := HardwareReservationFilter{Plan: req.Plan, Metro: req.Metro}
+		if len(req.Facility) == 1 && req.Facility[0] != "any" {
+			filter.Facility = req.Facility[0]
+		}
+		h, err := s.Claim(filter)
+		if err != nil {
+			return nil, err
+		}
+		req.HardwareReservationID = h.ID
+	default:
+		if err := s.ClaimID(req.HardwareReservationID); err != nil {
+			return nil, err
+		}
+	}
+	id := req.HardwareReservationID
+	return func() { s.Release(id) }, nil
+}
+
+// CreateDevice applies a reservation to req and creates the device, releasing
+// the claim and restoring req if the create fails. On success the reservation
+// stays claimed.
+func (s *HardwareReservationSelector) CreateDevice(devices DeviceService, req *DeviceCreateRequest) (*Device, *Response, error) {
+	requested := req.HardwareReservationID
+	release, err := s.Apply(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, resp, err := devices.Create(req)
+	if err != nil {
+		release()
+		req.HardwareReservationID = requested
+	}
+	return d, resp, err
+}
@@ -0,0 +1,176 @@
+package packngo
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeHardwareReservations struct {
+	HardwareReservationService
+	reservations []HardwareReservation
+}
+
+func (f *fakeHardwareReservations) List(projectID string, opts *ListOptions) ([]HardwareReservation, *Response, error) {
+	return f.reservations, nil, nil
+}
+
+type fakeReservedDevices struct {
+	DeviceService
+	err     error
+	created []string
+}
+
+func (f *fakeReservedDevices) Create(req *DeviceCreateRequest) (*Device, *Response, error) {
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	f.created = append(f.created, req.HardwareReservationID)
+	return &Device{ID: "dev-" + req.HardwareReservationID}, nil, nil
+}
+
+func testHardwareReservations() []HardwareReservation {
+	created := time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)
+	sv := Facility{Code: "sv15", Metro: &Metro{Code: "sv"}}
+	da := Facility{Code: "da11", Metro: &Metro{Code: "da"}}
+	ny := Facility{Code: "ny5", Metro: &Metro{Code: "ny"}}
+	small := Plan{Slug: "c3.small.x86"}
+	return []HardwareReservation{
+		// period ending 2021-12-20 with 11 periods left
+		{ID: "long", Plan: small, Facility: sv, Provisionable: true, Intervals: 12, CurrentPeriod: 2, CreatedAt: Timestamp{time.Date(2021, 10, 20, 0, 0, 0, 0, time.UTC)}},
+		// period ending 2021-12-15 with 2 periods left
+		{ID: "expiring", Plan: small, Facility: sv, Provisionable: true, Intervals: 12, CurrentPeriod: 11, CreatedAt: Timestamp{created}},
+		{ID: "spare", Plan: small, Facility: sv, Provisionable: true, Spare: true, Intervals: 12, CurrentPeriod: 12, CreatedAt: Timestamp{created}},
+		{ID: "in-use", Plan: small, Facility: sv, Intervals: 12, CurrentPeriod: 12, Device: &Device{ID: "d"}, CreatedAt: Timestamp{created}},
+		{ID: "dallas", Plan: small, Facility: da, Provisionable: true, Intervals: 12, CurrentPeriod: 12, CreatedAt: Timestamp{created}},
+		{ID: "large", Plan: Plan{Slug: "m3.large.x86"}, Facility: sv, Provisionable: true, Intervals: 12, CurrentPeriod: 12, CreatedAt: Timestamp{created}},
+		// period ending 2021-05-01 with 2 periods left
+		{ID: "short-term", Plan: small, Facility: ny, Provisionable: true, Intervals: 3, CurrentPeriod: 2, CreatedAt: Timestamp{time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}},
+		// period ending 2021-04-20 with 34 periods left
+		{ID: "period-ending", Plan: small, Facility: ny, Provisionable: true, Intervals: 36, CurrentPeriod: 3, CreatedAt: Timestamp{time.Date(2021, 1, 20, 0, 0, 0, 0, time.UTC)}},
+	}
+}
+
+func TestHardwareReservation_Periods(t *testing.T) {
+	h := HardwareReservation{Intervals: 12, CurrentPeriod: 3, CreatedAt: Timestamp{time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC)}}
+	if got := h.CurrentPeriodEnd(); !got.Equal(time.Date(2021, 4, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("CurrentPeriodEnd() = %s", got)
+	}
+	if got := h.TermEnd(); !got.Equal(time.Date(2022, 1, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("TermEnd() = %s", got)
+	}
+	if got := h.PeriodsRemaining(); got != 10 {
+		t.Errorf("PeriodsRemaining() = %d, want 10", got)
+	}
+}
+
+func TestHardwareReservationSelector_Candidates(t *testing.T) {
+	sel := NewHardwareReservationSelector(&fakeHardwareReservations{reservations: testHardwareReservations()}, "project")
+
+	tests := []struct {
+		name   string
+		filter HardwareReservationFilter
+		want   []string
+	}{
+		{name: "PlanMetro", filter: HardwareReservationFilter{Plan: "c3.small.x86", Metro: "SV"}, want: []string{"expiring", "long"}},
+		{name: "Spare", filter: HardwareReservationFilter{Plan: "c3.small.x86", Facility: "sv15", IncludeSpare: true}, want: []string{"expiring", "long", "spare"}},
+		{name: "Unprovisionable", filter: HardwareReservationFilter{Metro: "sv", IncludeUnprovisionable: true}, want: []string{"expiring", "long", "in-use", "large"}},
+		{name: "Facility", filter: HardwareReservationFilter{Facility: "da11"}, want: []string{"dallas"}},
+		{name: "CurrentPeriodEndFirst", filter: HardwareReservationFilter{Metro: "ny"}, want: []string{"period-ending", "short-term"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sel.Candidates(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, h := range got {
+				ids = append(ids, h.ID)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("Candidates() = %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("Candidates() = %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHardwareReservationSelector_ClaimConcurrent(t *testing.T) {
+	sel := NewHardwareReservationSelector(&fakeHardwareReservations{reservations: testHardwareReservations()}, "project")
+	filter := HardwareReservationFilter{Plan: "c3.small.x86", Metro: "sv"}
+
+	var wg sync.WaitGroup
+	claims := make(chan string, 4)
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h, err := sel.Claim(filter)
+			if err != nil {
+				errs <- err
+				return
+			}
+			claims <- h.ID
+		}()
+	}
+	wg.Wait()
+	close(claims)
+	close(errs)
+
+	seen := map[string]bool{}
+	for id := range claims {
+		if seen[id] {
+			t.Errorf("reservation %s claimed twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("expected 2 claims, got %v", seen)
+	}
+	for err := range errs {
+		if err != ErrNoHardwareReservation {
+			t.Errorf("expected ErrNoHardwareReservation, got %v", err)
+		}
+	}
+
+	sel.Release("long")
+	if h, err := sel.Claim(filter); err != nil || h.ID != "long" {
+		t.Errorf("expected released reservation to be claimed again, got %v, %v", h, err)
+	}
+}
+
+func TestHardwareReservationSelector_CreateDevice(t *testing.T) {
+	sel := NewHardwareReservationSelector(&fakeHardwareReservations{reservations: testHardwareReservations()}, "project")
+
+	devices := &fakeReservedDevices{err: errBoom}
+	req := &DeviceCreateRequest{Plan: "c3.small.x86", Facility: []string{"sv15"}, HardwareReservationID: HardwareReservationNextAvailable}
+	if _, _, err := sel.CreateDevice(devices, req); err != errBoom {
+		t.Fatalf("expected errBoom, got %v", err)
+	}
+	if req.HardwareReservationID != HardwareReservationNextAvailable {
+		t.Errorf("expected request to be restored, got %s", req.HardwareReservationID)
+	}
+
+	devices.err = nil
+	for _, want := range []string{"expiring", "long"} {
+		req := &DeviceCreateRequest{Plan: "c3.small.x86", Facility: []string{"sv15"}, HardwareReservationID: HardwareReservationNextAvailable}
+		d, _, err := sel.CreateDevice(devices, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.ID != "dev-"+want {
+			t.Errorf("expected device on %s, got %s", want, d.ID)
+		}
+	}
+
+	req = &DeviceCreateRequest{HardwareReservationID: "long"}
+	if _, _, err := sel.CreateDevice(devices, req); err == nil {
+		t.Error("expected error creating on a claimed reservation")
+	}
+}
@@ -0,0 +1,10 @@
+// Package ignition builds Ignition configs for Equinix Metal devices running
+// Flatcar Container Linux or Fedora CoreOS.
+//
+// Ignition configs are passed to devices as UserData. A Config can be applied
+// to a packngo.DeviceCreateRequest, packngo.BatchCreateDevice or
+// packngo.SpotMarketRequestInstanceParameters.
+//
+// For more information, see
+// https://coreos.github.io/ignition/specs/
+package ignition
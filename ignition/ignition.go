@@ -0,0 +1,480 @@
+package ignition
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/packethost/packngo"
+)
+
+// DefaultVersion is the Ignition spec version used by New
+const DefaultVersion = "3.3.0"
+
+// supportedVersions lists the Ignition spec versions Validate understands
+var supportedVersions = []string{"3.0.0", "3.1.0", "3.2.0", "3.3.0", "3.4.0"}
+
+// sourceSchemes maps URL schemes accepted in file sources to the spec version
+// that introduced them
+var sourceSchemes = map[string]string{
+	"data":  "3.0.0",
+	"http":  "3.0.0",
+	"https": "3.0.0",
+	"s3":    "3.0.0",
+	"tftp":  "3.0.0",
+	"gs":    "3.1.0",
+	"arn":   "3.2.0",
+}
+
+var filesystemFormats = []string{"ext4", "btrfs", "xfs", "vfat", "swap", "none"}
+
+var unitSuffixes = []string{".service", ".socket", ".device", ".mount", ".automount", ".swap", ".target", ".path", ".timer", ".slice", ".scope"}
+
+// Config is an Ignition v3 config
+//
+//	cfg := ignition.New().
+//		SSHKeys("core", "ssh-ed25519 AAAA...").
+//		Unit(ignition.Unit{Name: "app.service", Enabled: ignition.Bool(true), Contents: unit}).
+//		File("/etc/hostname", 0644, "web-1")
+//	err := cfg.ApplyToCreate(createRequest)
+type Config struct {
+	Ignition Ignition `json:"ignition"`
+	Passwd   *Passwd  `json:"passwd,omitempty"`
+	Systemd  *Systemd `json:"systemd,omitempty"`
+	Storage  *Storage `json:"storage,omitempty"`
+}
+
+// Ignition holds the config metadata
+type Ignition struct {
+	Version string `json:"version"`
+}
+
+// Passwd holds the users to create
+type Passwd struct {
+	Users []User `json:"users,omitempty"`
+}
+
+// User is a user account. Existing users, such as "core", are amended.
+type User struct {
+	Name              string   `json:"name"`
+	Groups            []string `json:"groups,omitempty"`
+	Shell             string   `json:"shell,omitempty"`
+	PasswordHash      *string  `json:"passwordHash,omitempty"`
+	SSHAuthorizedKeys []string `json:"sshAuthorizedKeys,omitempty"`
+}
+
+// Systemd holds the systemd units to install
+type Systemd struct {
+	Units []Unit `json:"units,omitempty"`
+}
+
+// Unit is a systemd unit
+type Unit struct {
+	Name     string   `json:"name"`
+	Enabled  *bool    `json:"enabled,omitempty"`
+	Mask     *bool    `json:"mask,omitempty"`
+	Contents string   `json:"contents,omitempty"`
+	Dropins  []Dropin `json:"dropins,omitempty"`
+}
+
+// Dropin is a systemd unit drop-in
+type Dropin struct {
+	Name     string `json:"name"`
+	Contents string `json:"contents,omitempty"`
+}
+
+// Storage holds the disks, filesystems, files, directories and links to
+// create
+type Storage struct {
+	Disks       []Disk       `json:"disks,omitempty"`
+	Filesystems []Filesystem `json:"filesystems,omitempty"`
+	Files       []File       `json:"files,omitempty"`
+	Directories []Directory  `json:"directories,omitempty"`
+	Links       []Link       `json:"links,omitempty"`
+}
+
+// Disk is a disk to partition
+type Disk struct {
+	Device     string      `json:"device"`
+	WipeTable  *bool       `json:"wipeTable,omitempty"`
+	Partitions []Partition `json:"partitions,omitempty"`
+}
+
+// Partition is a partition of a Disk
+type Partition struct {
+	Label    *string `json:"label,omitempty"`
+	Number   int     `json:"number,omitempty"`
+	SizeMiB  *int    `json:"sizeMiB,omitempty"`
+	StartMiB *int    `json:"startMiB,omitempty"`
+	TypeGUID *string `json:"typeGuid,omitempty"`
+}
+
+// Filesystem is a filesystem to create
+type Filesystem struct {
+	Device         string  `json:"device"`
+	Format         *string `json:"format,omitempty"`
+	Path           *string `json:"path,omitempty"`
+	Label          *string `json:"label,omitempty"`
+	WipeFilesystem *bool   `json:"wipeFilesystem,omitempty"`
+}
+
+// Node holds the fields common to files, directories and links
+type Node struct {
+	Path      string   `json:"path"`
+	Overwrite *bool    `json:"overwrite,omitempty"`
+	User      *NodeRef `json:"user,omitempty"`
+	Group     *NodeRef `json:"group,omitempty"`
+}
+
+// NodeRef refers to a user or group by name or ID
+type NodeRef struct {
+	ID   *int    `json:"id,omitempty"`
+	Name *string `json:"name,omitempty"`
+}
+
+// File is a file to write
+type File struct {
+	Node
+	Mode     *int     `json:"mode,omitempty"`
+	Contents Resource `json:"contents,omitempty"`
+}
+
+// Directory is a directory to create
+type Directory struct {
+	Node
+	Mode *int `json:"mode,omitempty"`
+}
+
+// Link is a symbolic or hard link to create
+type Link struct {
+	Node
+	Target string `json:"target"`
+	Hard   *bool  `json:"hard,omitempty"`
+}
+
+// Resource is the source of file contents
+type Resource struct {
+	Source      *string `json:"source,omitempty"`
+	Compression *string `json:"compression,omitempty"`
+}
+
+// Bool returns a pointer to b
+func Bool(b bool) *bool { return &b }
+
+// Int returns a pointer to i
+func Int(i int) *int { return &i }
+
+// String returns a pointer to s
+func String(s string) *string { return &s }
+
+// DataURL returns an RFC 2397 data URL holding contents
+func DataURL(contents string) string {
+	return "data:;base64," + base64.StdEncoding.EncodeToString([]byte(contents))
+}
+
+// New returns an empty Config using DefaultVersion
+func New() *Config {
+	return &Config{Ignition: Ignition{Version: DefaultVersion}}
+}
+
+// Version sets the Ignition spec version
+func (c *Config) Version(version string) *Config {
+	c.Ignition.Version = version
+	return c
+}
+
+// User adds a user, merging groups and SSH keys into an existing entry of
+// the same name
+func (c *Config) User(u User) *Config {
+	if c.Passwd == nil {
+		c.Passwd = &Passwd{}
+	}
+	for i := range c.Passwd.Users {
+		existing := &c.Passwd.Users[i]
+		if existing.Name != u.Name {
+			continue
+		}
+		existing.Groups = append(existing.Groups, u.Groups...)
+		existing.SSHAuthorizedKeys = append(existing.SSHAuthorizedKeys, u.SSHAuthorizedKeys...)
+		if u.Shell != "" {
+			existing.Shell = u.Shell
+		}
+		if u.PasswordHash != nil {
+			existing.PasswordHash = u.PasswordHash
+		}
+		return c
+	}
+	c.Passwd.Users = append(c.Passwd.Users, u)
+	return c
+}
+
+// SSHKeys authorizes SSH public keys for user
+func (c *Config) SSHKeys(user string, keys ...string) *Config {
+	return c.User(User{Name: user, SSHAuthorizedKeys: keys})
+}
+
+// ProjectSSHKeys authorizes every SSH key of an Equinix Metal project for user
+func (c *Config) ProjectSSHKeys(keys packngo.SSHKeyService, projectID, user string) error {
+	projectKeys, _, err := keys.ProjectList(projectID)
+	if err != nil {
+		return err
+	}
+	authorized := make([]string, len(projectKeys))
+	for i, k := range projectKeys {
+		authorized[i] = k.Key
+	}
+	c.SSHKeys(user, authorized...)
+	return nil
+}
+
+// Unit adds a systemd unit
+func (c *Config) Unit(u Unit) *Config {
+	if c.Systemd == nil {
+		c.Systemd = &Systemd{}
+	}
+	c.Systemd.Units = append(c.Systemd.Units, u)
+	return c
+}
+
+func (c *Config) storage() *Storage {
+	if c.Storage == nil {
+		c.Storage = &Storage{}
+	}
+	return c.Storage
+}
+
+// File writes contents to filePath with the given mode, replacing any
+// existing file
+func (c *Config) File(filePath string, mode int, contents string) *Config {
+	return c.AddFile(File{
+		Node:     Node{Path: filePath, Overwrite: Bool(true)},
+		Mode:     Int(mode),
+		Contents: Resource{Source: String(DataURL(contents))},
+	})
+}
+
+// AddFile adds a file
+func (c *Config) AddFile(f File) *Config {
+	c.storage().Files = append(c.storage().Files, f)
+	return c
+}
+
+// Directory adds a directory
+func (c *Config) Directory(d Directory) *Config {
+	c.storage().Directories = append(c.storage().Directories, d)
+	return c
+}
+
+// Link adds a link
+func (c *Config) Link(l Link) *Config {
+	c.storage().Links = append(c.storage().Links, l)
+	return c
+}
+
+// Disk adds a disk to partition
+func (c *Config) Disk(d Disk) *Config {
+	c.storage().Disks = append(c.storage().Disks, d)
+	return c
+}
+
+// Filesystem adds a filesystem
+func (c *Config) Filesystem(f Filesystem) *Config {
+	c.storage().Filesystems = append(c.storage().Filesystems, f)
+	return c
+}
+
+// compareVersions compares two dotted versions, returning -1, 0 or 1
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		ai, _ := strconv.Atoi(as[i])
+		bi, _ := strconv.Atoi(bs[i])
+		switch {
+		case ai < bi:
+			return -1
+		case ai > bi:
+			return 1
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// Validate checks the config against its Ignition spec version
+func (c *Config) Validate() error {
+	version := c.Ignition.Version
+	supported := false
+	for _, v := range supportedVersions {
+		supported = supported || v == version
+	}
+	if !supported {
+		return fmt.Errorf("ignition.version %q is not one of %v", version, supportedVersions)
+	}
+
+	if c.Passwd != nil {
+		for i, u := range c.Passwd.Users {
+			if u.Name == "" {
+				return fmt.Errorf("passwd.users[%d].name is required", i)
+			}
+		}
+	}
+
+	if c.Systemd != nil {
+		names := map[string]bool{}
+		for i, u := range c.Systemd.Units {
+			if !hasSuffix(u.Name, unitSuffixes) {
+				return fmt.Errorf("systemd.units[%d].name %q must end with a unit type suffix such as .service", i, u.Name)
+			}
+			if names[u.Name] {
+				return fmt.Errorf("systemd.units[%d].name %q is defined more than once", i, u.Name)
+			}
+			names[u.Name] = true
+			for j, d := range u.Dropins {
+				if !strings.HasSuffix(d.Name, ".conf") {
+					return fmt.Errorf("systemd.units[%d].dropins[%d].name %q must end with .conf", i, j, d.Name)
+				}
+			}
+		}
+	}
+
+	if c.Storage == nil {
+		return nil
+	}
+	s := c.Storage
+
+	for i, d := range s.Disks {
+		if d.Device == "" {
+			return fmt.Errorf("storage.disks[%d].device is required", i)
+		}
+		numbers := map[int]bool{}
+		for j, p := range d.Partitions {
+			if p.Number == 0 {
+				continue
+			}
+			if numbers[p.Number] {
+				return fmt.Errorf("storage.disks[%d].partitions[%d].number %d is defined more than once", i, j, p.Number)
+			}
+			numbers[p.Number] = true
+		}
+	}
+	for i, f := range s.Filesystems {
+		if f.Device == "" {
+			return fmt.Errorf("storage.filesystems[%d].device is required", i)
+		}
+		if f.Format != nil && !contains(filesystemFormats, *f.Format) {
+			return fmt.Errorf("storage.filesystems[%d].format %q is not one of %v", i, *f.Format, filesystemFormats)
+		}
+		if f.Path != nil && !path.IsAbs(*f.Path) {
+			return fmt.Errorf("storage.filesystems[%d].path %q must be absolute", i, *f.Path)
+		}
+	}
+
+	paths := map[string]bool{}
+	checkNode := func(field string, n Node) error {
+		if !path.IsAbs(n.Path) {
+			return fmt.Errorf("%s.path %q must be absolute", field, n.Path)
+		}
+		if paths[n.Path] {
+			return fmt.Errorf("%s.path %q is defined more than once", field, n.Path)
+		}
+		paths[n.Path] = true
+		return nil
+	}
+	for i, f := range s.Files {
+		field := fmt.Sprintf("storage.files[%d]", i)
+		if err := checkNode(field, f.Node); err != nil {
+			return err
+		}
+		if f.Contents.Source != nil {
+			u, err := url.Parse(*f.Contents.Source)
+			if err != nil {
+				return fmt.Errorf("%s.contents.source: %w", field, err)
+			}
+			since, ok := sourceSchemes[u.Scheme]
+			if !ok || compareVersions(version, since) < 0 {
+				return fmt.Errorf("%s.contents.source scheme %q is not supported by ignition %s", field, u.Scheme, version)
+			}
+		}
+		if f.Contents.Compression != nil && compareVersions(version, "3.1.0") < 0 {
+			return fmt.Errorf("%s.contents.compression requires ignition 3.1.0 or later", field)
+		}
+	}
+	for i, d := range s.Directories {
+		if err := checkNode(fmt.Sprintf("storage.directories[%d]", i), d.Node); err != nil {
+			return err
+		}
+	}
+	for i, l := range s.Links {
+		field := fmt.Sprintf("storage.links[%d]", i)
+		if err := checkNode(field, l.Node); err != nil {
+			return err
+		}
+		if l.Target == "" {
+			return fmt.Errorf("%s.target is required", field)
+		}
+	}
+	return nil
+}
+
+// Render validates the config and returns its JSON encoding. It fails if the
+// result exceeds packngo.DeviceUserDataMaxSize.
+func (c *Config) Render() (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	if len(b) > packngo.DeviceUserDataMaxSize {
+		return "", fmt.Errorf("ignition config is %d bytes, exceeding the %d byte limit", len(b), packngo.DeviceUserDataMaxSize)
+	}
+	return string(b), nil
+}
+
+// ApplyToCreate sets the UserData of a DeviceCreateRequest
+func (c *Config) ApplyToCreate(req *packngo.DeviceCreateRequest) error {
+	s, err := c.Render()
+	if err != nil {
+		return err
+	}
+	req.UserData = s
+	return nil
+}
+
+// ApplyToBatch sets the UserData of a BatchCreateDevice
+func (c *Config) ApplyToBatch(req *packngo.BatchCreateDevice) error {
+	return c.ApplyToCreate(&req.DeviceCreateRequest)
+}
+
+// ApplyToSpotMarketRequest sets the UserData of the devices created by a spot
+// market request
+func (c *Config) ApplyToSpotMarketRequest(params *packngo.SpotMarketRequestInstanceParameters) error {
+	s, err := c.Render()
+	if err != nil {
+		return err
+	}
+	params.UserData = s
+	return nil
+}
+
+func contains(a []string, x string) bool {
+	for _, n := range a {
+		if x == n {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
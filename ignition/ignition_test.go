@@ -0,0 +1,100 @@
+package ignition
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/packethost/packngo"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSSHKeys struct {
+	packngo.SSHKeyService
+	keys []packngo.SSHKey
+}
+
+func (f *fakeSSHKeys) ProjectList(projectID string) ([]packngo.SSHKey, *packngo.Response, error) {
+	return f.keys, nil, nil
+}
+
+func Test_Render(t *testing.T) {
+	cfg := New().
+		SSHKeys("core", "ssh-ed25519 AAAA1").
+		User(coreUser()).
+		Unit(Unit{Name: "app.service", Enabled: Bool(true), Contents: "[Service]\nExecStart=/bin/true\n"}).
+		File("/etc/hostname", 0644, "web-1")
+
+	s, err := cfg.Render()
+	assert.Nil(t, err)
+
+	var got map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(s), &got))
+	assert.Equal(t, DefaultVersion, got["ignition"].(map[string]interface{})["version"])
+
+	users := got["passwd"].(map[string]interface{})["users"].([]interface{})
+	assert.Len(t, users, 1)
+	assert.Equal(t, []interface{}{"ssh-ed25519 AAAA1", "ssh-ed25519 AAAA2"}, users[0].(map[string]interface{})["sshAuthorizedKeys"])
+
+	file := got["storage"].(map[string]interface{})["files"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "/etc/hostname", file["path"])
+	assert.Equal(t, float64(0644), file["mode"])
+	assert.Equal(t, DataURL("web-1"), file["contents"].(map[string]interface{})["source"])
+}
+
+func coreUser() User {
+	return User{Name: "core", Groups: []string{"docker"}, SSHAuthorizedKeys: []string{"ssh-ed25519 AAAA2"}}
+}
+
+func Test_ProjectSSHKeys(t *testing.T) {
+	cfg := New()
+	keys := &fakeSSHKeys{keys: []packngo.SSHKey{{Key: "ssh-rsa A"}, {Key: "ssh-rsa B"}}}
+	assert.Nil(t, cfg.ProjectSSHKeys(keys, "project", "core"))
+	assert.Equal(t, []string{"ssh-rsa A", "ssh-rsa B"}, cfg.Passwd.Users[0].SSHAuthorizedKeys)
+}
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{"Valid", New().File("/etc/motd", 0644, "hi"), false},
+		{"UnknownVersion", New().Version("2.2.0"), true},
+		{"RelativePath", New().File("etc/motd", 0644, "hi"), true},
+		{"DuplicatePath", New().File("/etc/motd", 0644, "a").File("/etc/motd", 0644, "b"), true},
+		{"BadUnitName", New().Unit(Unit{Name: "app"}), true},
+		{"BadDropin", New().Unit(Unit{Name: "app.service", Dropins: []Dropin{{Name: "10-env"}}}), true},
+		{"BadFormat", New().Filesystem(Filesystem{Device: "/dev/sdb", Format: String("zfs")}), true},
+		{"DuplicatePartition", New().Disk(Disk{Device: "/dev/sdb", Partitions: []Partition{{Number: 1}, {Number: 1}}}), true},
+		{"CompressionTooOld", New().Version("3.0.0").AddFile(File{Node: Node{Path: "/a"}, Contents: Resource{Source: String("https://example.com/a.gz"), Compression: String("gzip")}}), true},
+		{"CompressionSupported", New().Version("3.1.0").AddFile(File{Node: Node{Path: "/a"}, Contents: Resource{Source: String("https://example.com/a.gz"), Compression: String("gzip")}}), false},
+		{"SchemeTooOld", New().Version("3.1.0").AddFile(File{Node: Node{Path: "/a"}, Contents: Resource{Source: String("arn:aws:s3:::bucket/a")}}), true},
+		{"LinkWithoutTarget", New().Link(Link{Node: Node{Path: "/a"}}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Apply(t *testing.T) {
+	cfg := New().SSHKeys("core", "ssh-ed25519 AAAA")
+	want, err := cfg.Render()
+	assert.Nil(t, err)
+
+	create := &packngo.DeviceCreateRequest{}
+	assert.Nil(t, cfg.ApplyToCreate(create))
+	assert.Equal(t, want, create.UserData)
+
+	batch := &packngo.BatchCreateDevice{}
+	assert.Nil(t, cfg.ApplyToBatch(batch))
+	assert.Equal(t, want, batch.UserData)
+
+	spot := &packngo.SpotMarketRequestInstanceParameters{}
+	assert.Nil(t, cfg.ApplyToSpotMarketRequest(spot))
+	assert.Equal(t, want, spot.UserData)
+}
@@ -111,6 +111,9 @@ type IPAddressReservation struct {
 	Enabled      bool                   `json:"enabled"`
 	MetalGateway *MetalGatewayLite      `json:"metal_gateway,omitempty"`
 	RequestedBy  *UserLite              `json:"requested_by,omitempty"`
+
+	// Extra holds the fields returned by the API that are not modeled above
+	Extra ExtraFields `json:"-"`
 }
 
 // AvailableResponse is a type for listing of available addresses from a reserved block.
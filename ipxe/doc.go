@@ -0,0 +1,11 @@
+// Package ipxe serves per-device iPXE scripts to Equinix Metal devices
+// provisioned with the custom_ipxe operating system or AlwaysPXE.
+//
+// A Server is an http.Handler that renders a text/template for the device
+// identified in the request path by its ID, hostname or MAC address. Devices
+// are resolved through the Equinix Metal API or the metadata service, and
+// every script fetch is recorded.
+//
+// For more information, see
+// https://metal.equinix.com/developers/docs/operating-systems/custom-ipxe/
+package ipxe
@@ -0,0 +1,330 @@
+package ipxe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/packethost/packngo"
+	"github.com/packethost/packngo/metadata"
+)
+
+const scriptHeader = "#!ipxe"
+
+// ErrNotFound is returned by a Lookup when no device matches the key
+var ErrNotFound = errors.New("device not found")
+
+// Device is the device information available to script templates
+type Device struct {
+	ID         string
+	Hostname   string
+	Plan       string
+	Metro      string
+	Facility   string
+	OS         string
+	Tags       []string
+	MACs       []string
+	CustomData map[string]interface{}
+}
+
+// DeviceFromAPI converts a device returned by the Equinix Metal API
+func DeviceFromAPI(d *packngo.Device) *Device {
+	dev := &Device{ID: d.ID, Hostname: d.Hostname, Tags: d.Tags, CustomData: d.CustomData}
+	if d.Plan != nil {
+		dev.Plan = d.Plan.Slug
+	}
+	if d.Metro != nil {
+		dev.Metro = d.Metro.Code
+	}
+	if d.Facility != nil {
+		dev.Facility = d.Facility.Code
+	}
+	if d.OS != nil {
+		dev.OS = d.OS.Slug
+	}
+	for _, p := range d.NetworkPorts {
+		if p.Data.MAC != "" {
+			dev.MACs = append(dev.MACs, strings.ToLower(p.Data.MAC))
+		}
+	}
+	return dev
+}
+
+// DeviceFromMetadata converts a device described by the metadata service
+func DeviceFromMetadata(d *metadata.CurrentDevice) *Device {
+	dev := &Device{
+		ID:         d.ID,
+		Hostname:   d.Hostname,
+		Plan:       d.Plan,
+		Metro:      d.Metro,
+		Facility:   d.Facility,
+		OS:         d.OS.Slug,
+		Tags:       d.Tags,
+		CustomData: d.CustomData,
+	}
+	for _, i := range d.Network.Interfaces {
+		dev.MACs = append(dev.MACs, strings.ToLower(i.MAC))
+	}
+	return dev
+}
+
+// matches reports whether key is the ID, hostname or one of the MAC
+// addresses of the device
+func (d *Device) matches(key string) bool {
+	if key == d.ID || key == d.Hostname {
+		return true
+	}
+	for _, mac := range d.MACs {
+		if strings.EqualFold(mac, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup resolves a device ID, hostname or MAC address to a Device
+type Lookup interface {
+	Lookup(key string) (*Device, error)
+}
+
+// LookupFunc adapts a function to the Lookup interface
+type LookupFunc func(key string) (*Device, error)
+
+// Lookup calls f(key)
+func (f LookupFunc) Lookup(key string) (*Device, error) {
+	return f(key)
+}
+
+// APILookup resolves devices of a project through the Equinix Metal API.
+// Device IDs are fetched directly; hostnames and MAC addresses are matched
+// against the project device list.
+type APILookup struct {
+	Devices   packngo.DeviceService
+	ProjectID string
+}
+
+// Lookup implements Lookup
+func (l *APILookup) Lookup(key string) (*Device, error) {
+	if packngo.ValidateUUID(key) == nil {
+		d, resp, err := l.Devices.Get(key, nil)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return DeviceFromAPI(d), nil
+	}
+
+	devices, _, err := l.Devices.List(l.ProjectID, nil)
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		if dev := DeviceFromAPI(&devices[i]); dev.matches(key) {
+			return dev, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// MetadataLookup resolves the device described by the metadata service at
+// baseURL, typically metadata.BaseURL when the server runs on the device
+// being booted, such as when chain loading from a rescue environment.
+func MetadataLookup(baseURL string) Lookup {
+	return LookupFunc(func(key string) (*Device, error) {
+		md, err := metadata.GetMetadataFromURL(baseURL)
+		if err != nil {
+			return nil, err
+		}
+		dev := DeviceFromMetadata(md)
+		if !dev.matches(key) {
+			return nil, ErrNotFound
+		}
+		return dev, nil
+	})
+}
+
+// TemplateData is passed to script templates
+type TemplateData struct {
+	// Key is the ID, hostname or MAC address used to request the script
+	Key    string
+	Device *Device
+
+	// Query holds the query parameters of the request, such as values
+	// substituted by iPXE
+	Query url.Values
+}
+
+// Fetch records the GET requests of a device for its script
+type Fetch struct {
+	DeviceID   string
+	Hostname   string
+	RemoteAddr string
+	First      time.Time
+	Last       time.Time
+	Count      int
+}
+
+// Server is an http.Handler that serves iPXE scripts at "<prefix>/<key>",
+// where key is a device ID, hostname or MAC address
+type Server struct {
+	lookup Lookup
+
+	mu        sync.Mutex
+	fallback  *template.Template
+	templates map[string]*template.Template
+	fetches   map[string]*Fetch
+}
+
+// NewServer returns a Server rendering fallback for devices without a
+// device specific template
+func NewServer(lookup Lookup, fallback *template.Template) *Server {
+	return &Server{
+		lookup:    lookup,
+		fallback:  fallback,
+		templates: map[string]*template.Template{},
+		fetches:   map[string]*Fetch{},
+	}
+}
+
+// SetTemplate renders tmpl for the device with the given ID, hostname or MAC
+// address
+func (s *Server) SetTemplate(key string, tmpl *template.Template) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates[strings.ToLower(key)] = tmpl
+}
+
+// template returns the most specific template for dev
+func (s *Server) template(dev *Device) *template.Template {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := append([]string{dev.ID, dev.Hostname}, dev.MACs...)
+	for _, k := range keys {
+		if t, ok := s.templates[strings.ToLower(k)]; ok {
+			return t
+		}
+	}
+	return s.fallback
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := path.Base(r.URL.Path)
+	if key == "/" || key == "." {
+		http.Error(w, "device key required", http.StatusNotFound)
+		return
+	}
+
+	dev, err := s.lookup.Lookup(key)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	tmpl := s.template(dev)
+	if tmpl == nil {
+		http.Error(w, fmt.Sprintf("no script for device %s", dev.ID), http.StatusNotFound)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, TemplateData{Key: key, Device: dev, Query: r.URL.Query()}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	script := buf.String()
+	if !strings.HasPrefix(script, scriptHeader) {
+		script = scriptHeader + "\n" + script
+	}
+
+	// HEAD requests, e.g. from health checks, are not fetches by the device
+	if r.Method == http.MethodGet {
+		s.record(dev, r.RemoteAddr)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(script))
+}
+
+func (s *Server) record(dev *Device, remoteAddr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	f, ok := s.fetches[dev.ID]
+	if !ok {
+		f = &Fetch{DeviceID: dev.ID, First: now}
+		s.fetches[dev.ID] = f
+	}
+	f.Hostname = dev.Hostname
+	f.RemoteAddr = remoteAddr
+	f.Last = now
+	f.Count++
+}
+
+// Fetched returns the fetch record of a device and whether it has fetched
+// its script
+func (s *Server) Fetched(deviceID string) (Fetch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.fetches[deviceID]
+	if !ok {
+		return Fetch{}, false
+	}
+	return *f, true
+}
+
+// Fetches returns the fetch records of every device, ordered by first fetch
+func (s *Server) Fetches() []Fetch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fetches := make([]Fetch, 0, len(s.fetches))
+	for _, f := range s.fetches {
+		fetches = append(fetches, *f)
+	}
+	sort.Slice(fetches, func(i, j int) bool { return fetches[i].First.Before(fetches[j].First) })
+	return fetches
+}
+
+// ScriptURL returns the URL of the script for key on a Server mounted at
+// baseURL
+func ScriptURL(baseURL, key string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + url.PathEscape(key)
+}
+
+// ApplyToCreate boots a new device from the script served for its hostname.
+// The operating system is set to custom_ipxe.
+func ApplyToCreate(req *packngo.DeviceCreateRequest, baseURL string, alwaysPXE bool) error {
+	if req.Hostname == "" {
+		return fmt.Errorf("a hostname is required to identify the device")
+	}
+	req.OS = packngo.OSCustomIPXE
+	req.IPXEScriptURL = ScriptURL(baseURL, req.Hostname)
+	req.AlwaysPXE = alwaysPXE
+	return nil
+}
+
+// ApplyToUpdate boots an existing device from the script served for its ID
+func ApplyToUpdate(req *packngo.DeviceUpdateRequest, baseURL, deviceID string, alwaysPXE bool) {
+	u := ScriptURL(baseURL, deviceID)
+	req.IPXEScriptURL = &u
+	req.AlwaysPXE = &alwaysPXE
+}
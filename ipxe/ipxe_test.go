@@ -0,0 +1,120 @@
+package ipxe
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+
+	"github.com/packethost/packngo"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDeviceID = "9307dc37-7f39-400b-9cd2-009087434a95"
+
+var testDevices = []packngo.Device{{
+	ID:           testDeviceID,
+	Hostname:     "node-1",
+	Plan:         &packngo.Plan{Slug: "c3.small.x86"},
+	Metro:        &packngo.Metro{Code: "sv"},
+	NetworkPorts: []packngo.Port{{Data: packngo.PortData{MAC: "0C:C4:7A:E5:42:EA"}}},
+}}
+
+type fakeDevices struct {
+	packngo.DeviceService
+}
+
+func (f *fakeDevices) Get(id string, opts *packngo.GetOptions) (*packngo.Device, *packngo.Response, error) {
+	for _, d := range testDevices {
+		if d.ID == id {
+			return &d, nil, nil
+		}
+	}
+	return nil, &packngo.Response{Response: &http.Response{StatusCode: http.StatusNotFound}}, &packngo.ErrorResponse{}
+}
+
+func (f *fakeDevices) List(projectID string, opts *packngo.ListOptions) ([]packngo.Device, *packngo.Response, error) {
+	return testDevices, nil, nil
+}
+
+func get(t *testing.T, url string) (int, string) {
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, string(b)
+}
+
+func Test_Server(t *testing.T) {
+	fallback := template.Must(template.New("default").Parse("chain http://boot/{{.Device.Plan}}/{{.Device.Metro}}\n"))
+	srv := NewServer(&APILookup{Devices: &fakeDevices{}}, fallback)
+	ts := httptest.NewServer(http.StripPrefix("/ipxe", srv))
+	defer ts.Close()
+
+	for _, key := range []string{testDeviceID, "node-1", "0c:c4:7a:e5:42:ea"} {
+		code, body := get(t, ScriptURL(ts.URL+"/ipxe/", key))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "#!ipxe\nchain http://boot/c3.small.x86/sv\n", body)
+	}
+
+	srv.SetTemplate("node-1", template.Must(template.New("node").Parse("#!ipxe\necho {{.Key}} {{.Query.Get \"arch\"}}\n")))
+	code, body := get(t, ts.URL+"/ipxe/node-1?arch=x86_64")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "#!ipxe\necho node-1 x86_64\n", body)
+
+	resp, err := http.Head(ts.URL + "/ipxe/node-1")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	code, _ = get(t, ts.URL+"/ipxe/node-2")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get(t, ts.URL+"/ipxe/00000000-0000-0000-0000-000000000000")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	f, ok := srv.Fetched(testDeviceID)
+	assert.True(t, ok)
+	assert.Equal(t, 4, f.Count)
+	assert.Equal(t, "node-1", f.Hostname)
+	assert.Len(t, srv.Fetches(), 1)
+}
+
+func Test_MetadataLookup(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metadata", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"` + testDeviceID + `","hostname":"node-1","plan":"c3.small.x86",
+			"network":{"interfaces":[{"name":"eth0","mac":"0C:C4:7A:E5:42:EA"}]}}`))
+	})
+	md := httptest.NewServer(mux)
+	defer md.Close()
+
+	l := MetadataLookup(md.URL)
+	dev, err := l.Lookup("0c:c4:7a:e5:42:ea")
+	assert.Nil(t, err)
+	assert.Equal(t, "c3.small.x86", dev.Plan)
+
+	_, err = l.Lookup("node-2")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func Test_Apply(t *testing.T) {
+	create := &packngo.DeviceCreateRequest{}
+	assert.Error(t, ApplyToCreate(create, "https://boot.example.com/ipxe", true))
+
+	create.Hostname = "node-1"
+	assert.Nil(t, ApplyToCreate(create, "https://boot.example.com/ipxe/", true))
+	assert.Equal(t, "https://boot.example.com/ipxe/node-1", create.IPXEScriptURL)
+	assert.Equal(t, packngo.OSCustomIPXE, create.OS)
+	assert.True(t, create.AlwaysPXE)
+
+	update := &packngo.DeviceUpdateRequest{}
+	ApplyToUpdate(update, "https://boot.example.com/ipxe", testDeviceID, false)
+	assert.Equal(t, "https://boot.example.com/ipxe/"+testDeviceID, *update.IPXEScriptURL)
+	assert.False(t, *update.AlwaysPXE)
+}
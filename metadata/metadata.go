@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"time"
 )
 
 const BaseURL = "https://metadata.platformequinix.com"
@@ -121,11 +122,19 @@ type CurrentDevice struct {
 	Network    NetworkInfo            `json:"network"`
 	Volumes    []VolumeInfo           `json:"volumes"`
 	CustomData map[string]interface{} `json:"customdata"`
+	Spot       *SpotInfo              `json:"spot,omitempty"`
 
 	// This is available, but is actually inaccurate, currently:
 	//   APIBaseURL string          `json:"api_url"`
 }
 
+// SpotInfo is present for spot market instances
+type SpotInfo struct {
+	// TerminationTime is set once the instance has been scheduled for
+	// reclamation
+	TerminationTime *time.Time `json:"termination_time,omitempty"`
+}
+
 type InterfaceInfo struct {
 	Name string `json:"name"`
 	MAC  string `json:"mac"`
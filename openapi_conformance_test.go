@@ -0,0 +1,363 @@
+package packngo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISpecFile is the vendored Metal OpenAPI document, refreshed with
+// "make fetch-openapi"
+const openAPISpecFile = "spec/metal-openapi.json"
+
+// openAPIStrictEnvVar fails TestOpenAPIConformance on any issue
+const openAPIStrictEnvVar = "PACKNGO_OPENAPI_STRICT"
+
+// OpenAPI conformance issue kinds
+const (
+	openAPISchemaNotFound    = "schema_not_found"
+	openAPIMissingField      = "missing_field"
+	openAPIExtraField        = "extra_field"
+	openAPITypeMismatch      = "type_mismatch"
+	openAPIRequiredOmitEmpty = "required_omitempty"
+)
+
+// openAPITypes maps packngo types to the components.schemas of the OpenAPI
+// document. The required properties of Request schemas must not be omitempty.
+var openAPITypes = []struct {
+	Schema  string
+	Value   interface{}
+	Request bool
+}{
+	{Schema: "Batch", Value: Batch{}},
+	{Schema: "BgpSession", Value: BGPSession{}},
+	{Schema: "Device", Value: Device{}},
+	{Schema: "Event", Value: Event{}},
+	{Schema: "Facility", Value: Facility{}},
+	{Schema: "HardwareReservation", Value: HardwareReservation{}},
+	{Schema: "Interconnection", Value: Connection{}},
+	{Schema: "IPReservation", Value: IPAddressReservation{}},
+	{Schema: "MetalGateway", Value: MetalGateway{}},
+	{Schema: "Metro", Value: Metro{}},
+	{Schema: "Organization", Value: Organization{}},
+	{Schema: "Plan", Value: Plan{}},
+	{Schema: "Port", Value: Port{}},
+	{Schema: "Project", Value: Project{}},
+	{Schema: "SpotMarketRequest", Value: SpotMarketRequest{}},
+	{Schema: "SSHKey", Value: SSHKey{}},
+	{Schema: "User", Value: User{}},
+	{Schema: "VirtualNetwork", Value: VirtualNetwork{}},
+	{Schema: "VlanVirtualCircuit", Value: VirtualCircuit{}},
+	{Schema: "Vrf", Value: VRF{}},
+
+	{Schema: "DeviceCreateInMetroInput", Value: DeviceCreateRequest{}, Request: true},
+	{Schema: "DeviceUpdateInput", Value: DeviceUpdateRequest{}, Request: true},
+	{Schema: "InstancesBatchCreateInput", Value: BatchCreateRequest{}, Request: true},
+	{Schema: "ProjectCreateFromRootInput", Value: ProjectCreateRequest{}, Request: true},
+	{Schema: "SpotMarketRequestCreateInput", Value: SpotMarketRequestCreateRequest{}, Request: true},
+	{Schema: "SSHKeyCreateInput", Value: SSHKeyCreateRequest{}, Request: true},
+	{Schema: "VrfCreateInput", Value: VRFCreateRequest{}, Request: true},
+}
+
+// openAPISchema is the subset of an OpenAPI schema object compared with Go
+// types
+type openAPISchema struct {
+	Ref        string                    `yaml:"$ref"`
+	Type       string                    `yaml:"type"`
+	Format     string                    `yaml:"format"`
+	Properties map[string]*openAPISchema `yaml:"properties"`
+	Items      *openAPISchema            `yaml:"items"`
+	Required   []string                  `yaml:"required"`
+	AllOf      []*openAPISchema          `yaml:"allOf"`
+}
+
+// openAPIDocument is an OpenAPI 3 document. yaml.v3 also reads JSON.
+type openAPIDocument struct {
+	Components struct {
+		Schemas map[string]*openAPISchema `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+// openAPIIssue is a difference between a Go type and its schema
+type openAPIIssue struct {
+	Type   string
+	Schema string
+	Field  string
+	Kind   string
+	Detail string
+}
+
+func (i openAPIIssue) String() string {
+	return fmt.Sprintf("%s (%s) %s: %s %s", i.Type, i.Schema, i.Field, i.Kind, i.Detail)
+}
+
+func loadOpenAPIDocument(name string) (*openAPIDocument, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return parseOpenAPIDocument(data)
+}
+
+func parseOpenAPIDocument(data []byte) (*openAPIDocument, error) {
+	doc := new(openAPIDocument)
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// resolve follows $ref and merges allOf into a single schema
+func (d *openAPIDocument) resolve(s *openAPISchema) *openAPISchema {
+	for depth := 0; s != nil && s.Ref != "" && depth < 32; depth++ {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	if s == nil || len(s.AllOf) == 0 {
+		return s
+	}
+
+	merged := &openAPISchema{Type: s.Type, Format: s.Format, Items: s.Items, Properties: map[string]*openAPISchema{}}
+	for _, part := range append([]*openAPISchema{{Properties: s.Properties, Required: s.Required}}, s.AllOf...) {
+		part = d.resolve(part)
+		if part == nil {
+			continue
+		}
+		if merged.Type == "" {
+			merged.Type = part.Type
+		}
+		for name, p := range part.Properties {
+			merged.Properties[name] = p
+		}
+		merged.Required = append(merged.Required, part.Required...)
+	}
+	return merged
+}
+
+// check compares the JSON fields of Go type t with the named schema
+func (d *openAPIDocument) check(schemaName string, t reflect.Type, request bool) []openAPIIssue {
+	issue := func(field, kind, detail string) openAPIIssue {
+		return openAPIIssue{Type: t.Name(), Schema: schemaName, Field: field, Kind: kind, Detail: detail}
+	}
+
+	schema := d.resolve(d.Components.Schemas[schemaName])
+	if schema == nil {
+		return []openAPIIssue{issue("", openAPISchemaNotFound, "")}
+	}
+
+	var issues []openAPIIssue
+	fields := jsonFields(t)
+	for name, prop := range schema.Properties {
+		f, ok := fields[strings.ToLower(name)]
+		if !ok {
+			issues = append(issues, issue(name, openAPIMissingField, openAPIType(d.resolve(prop))))
+			continue
+		}
+		if want, ok := openAPITypeMatches(d, d.resolve(prop), f); !ok {
+			issues = append(issues, issue(name, openAPITypeMismatch, fmt.Sprintf("spec %s, Go %s", want, f.Type)))
+		}
+	}
+	for name := range fields {
+		found := false
+		for prop := range schema.Properties {
+			found = found || strings.EqualFold(prop, name)
+		}
+		if !found {
+			issues = append(issues, issue(name, openAPIExtraField, fields[name].Type.String()))
+		}
+	}
+	if request {
+		for _, name := range schema.Required {
+			if f, ok := fields[strings.ToLower(name)]; ok && f.OmitEmpty {
+				issues = append(issues, issue(name, openAPIRequiredOmitEmpty, ""))
+			}
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Field != issues[j].Field {
+			return issues[i].Field < issues[j].Field
+		}
+		return issues[i].Kind < issues[j].Kind
+	})
+	return issues
+}
+
+func openAPIType(s *openAPISchema) string {
+	switch {
+	case s == nil:
+		return "unknown"
+	case s.Format != "":
+		return s.Type + "/" + s.Format
+	case s.Type == "" && len(s.Properties) > 0:
+		return "object"
+	}
+	return s.Type
+}
+
+// openAPITypeMatches reports whether the Go field f can hold values of the
+// schema s, returning the schema type
+func openAPITypeMatches(d *openAPIDocument, s *openAPISchema, f jsonField) (string, bool) {
+	want := openAPIType(s)
+	t := f.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if s == nil || t.Kind() == reflect.Interface {
+		return want, true
+	}
+	if f.String {
+		return want, true
+	}
+
+	switch want {
+	case "string/date-time":
+		return want, t == timestampType || t == reflect.TypeOf(Timestamp{}.Time)
+	case "object", "":
+		return want, t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+	case "array":
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return want, false
+		}
+		item := d.resolve(s.Items)
+		if item == nil {
+			return want, true
+		}
+		_, ok := openAPITypeMatches(d, item, jsonField{Type: t.Elem()})
+		return want + " of " + openAPIType(item), ok
+	}
+
+	switch s.Type {
+	case "string":
+		return want, t.Kind() == reflect.String
+	case "boolean":
+		return want, t.Kind() == reflect.Bool
+	case "integer":
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return want, true
+		}
+		return want, false
+	case "number":
+		switch t.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return want, true
+		}
+		return want, false
+	}
+	return want, true
+}
+
+const testOpenAPIDocument = `{
+  "components": {
+    "schemas": {
+      "Href": {"type": "object", "properties": {"href": {"type": "string"}}},
+      "Base": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string", "format": "uuid"},
+          "created_at": {"type": "string", "format": "date-time"}
+        }
+      },
+      "Widget": {
+        "allOf": [{"$ref": "#/components/schemas/Base"}],
+        "properties": {
+          "name": {"type": "string"},
+          "size": {"type": "integer"},
+          "price": {"type": "number"},
+          "tags": {"type": "array", "items": {"type": "string"}},
+          "project": {"$ref": "#/components/schemas/Href"},
+          "spec_only": {"type": "boolean"}
+        }
+      },
+      "WidgetInput": {
+        "type": "object",
+        "required": ["name", "size"],
+        "properties": {
+          "name": {"type": "string"},
+          "size": {"type": "integer"}
+        }
+      }
+    }
+  }
+}`
+
+type testOpenAPIWidget struct {
+	ID        string   `json:"id"`
+	CreatedAt string   `json:"created_at"`
+	Name      string   `json:"name"`
+	Size      float64  `json:"size"`
+	Price     float32  `json:"price,string"`
+	Tags      []int    `json:"tags"`
+	Project   *Href    `json:"project"`
+	GoOnly    []string `json:"go_only"`
+}
+
+type testOpenAPIWidgetInput struct {
+	Name string `json:"name"`
+	Size int    `json:"size,omitempty"`
+}
+
+func TestOpenAPIDocument_Check(t *testing.T) {
+	doc, err := parseOpenAPIDocument([]byte(testOpenAPIDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := "testOpenAPIWidget"
+	expected := []openAPIIssue{
+		{w, "Widget", "created_at", openAPITypeMismatch, "spec string/date-time, Go string"},
+		{w, "Widget", "go_only", openAPIExtraField, "[]string"},
+		{w, "Widget", "size", openAPITypeMismatch, "spec integer, Go float64"},
+		{w, "Widget", "spec_only", openAPIMissingField, "boolean"},
+		{w, "Widget", "tags", openAPITypeMismatch, "spec array of string, Go []int"},
+	}
+	if diff := cmp.Diff(expected, doc.check("Widget", reflect.TypeOf(testOpenAPIWidget{}), false)); diff != "" {
+		t.Error(diff)
+	}
+
+	expected = []openAPIIssue{{"testOpenAPIWidgetInput", "WidgetInput", "size", openAPIRequiredOmitEmpty, ""}}
+	if diff := cmp.Diff(expected, doc.check("WidgetInput", reflect.TypeOf(testOpenAPIWidgetInput{}), true)); diff != "" {
+		t.Error(diff)
+	}
+
+	expected = []openAPIIssue{{"testOpenAPIWidget", "Gadget", "", openAPISchemaNotFound, ""}}
+	if diff := cmp.Diff(expected, doc.check("Gadget", reflect.TypeOf(testOpenAPIWidget{}), false)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+// TestOpenAPIConformance reports the differences between packngo types and
+// the vendored OpenAPI document. Run with -v to see them, and set
+// PACKNGO_OPENAPI_STRICT to fail on any difference or a missing document.
+func TestOpenAPIConformance(t *testing.T) {
+	strict := os.Getenv(openAPIStrictEnvVar) != ""
+	doc, err := loadOpenAPIDocument(openAPISpecFile)
+	if os.IsNotExist(err) {
+		if strict {
+			t.Fatalf("%s not found, run make fetch-openapi and commit it", openAPISpecFile)
+		}
+		t.Skipf("%s not found, run make fetch-openapi", openAPISpecFile)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for _, m := range openAPITypes {
+		for _, issue := range doc.check(m.Schema, reflect.TypeOf(m.Value), m.Request) {
+			t.Log(issue)
+			count++
+		}
+	}
+	if count > 0 && strict {
+		t.Errorf("%d differences from %s", count, openAPISpecFile)
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -92,6 +91,12 @@ type Client struct {
 	APIKey        string
 	apiKeySet     bool
 	header        http.Header
+	policies      []Policy
+	readOnly      bool
+	auditSink     AuditSink
+	auditActor    string
+	drift         *DriftReport
+	budget        *budgetGuard
 
 	RateLimit Rate
 
@@ -151,6 +156,16 @@ type requestDoer interface {
 
 // NewRequest inits a new http request with the proper headers
 func (c *Client) NewRequest(method, path string, body interface{}) (*http.Request, error) {
+	if c.readOnly && method != "GET" {
+		return nil, &ReadOnlyError{Method: method, Path: path}
+	}
+	if err := c.checkPolicies(method, path, body); err != nil {
+		return nil, err
+	}
+	if err := c.checkBudget(method, path, body); err != nil {
+		return nil, err
+	}
+
 	// relative path to append to the endpoint url, no leading slash please
 	if path[0] == '/' {
 		path = path[1:]
@@ -188,13 +203,35 @@ func (c *Client) NewRequest(method, path string, body interface{}) (*http.Reques
 
 // Do executes the http request
 func (c *Client) Do(req *http.Request, v interface{}) (*Response, error) {
+	if c.readOnly && req.Method != "GET" {
+		return nil, &ReadOnlyError{Method: req.Method, Path: req.URL.Path}
+	}
+	var audit *AuditRecord
+	if c.auditing(req) {
+		record := c.newAuditRecord(req)
+		audit = &record
+	}
+
 	resp, err := c.client.Do(req)
 	if err != nil {
+		if audit != nil {
+			c.writeAudit(*audit, nil, nil, err)
+		}
 		return nil, err
 	}
 
 	defer resp.Body.Close()
 
+	var auditBody []byte
+	if audit != nil {
+		auditBody, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			c.writeAudit(*audit, resp, nil, err)
+			return &Response{Response: resp}, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(auditBody))
+	}
+
 	response := Response{Response: resp}
 	response.populateRate()
 	if c.debug {
@@ -204,6 +241,9 @@ func (c *Client) Do(req *http.Request, v interface{}) (*Response, error) {
 	c.RateLimit = response.Rate
 
 	err = checkResponse(resp)
+	if audit != nil {
+		c.writeAudit(*audit, resp, auditBody, err)
+	}
 	// if the response is an error, return the ErrorResponse
 	if err != nil {
 		return &response, err
@@ -216,6 +256,16 @@ func (c *Client) Do(req *http.Request, v interface{}) (*Response, error) {
 			if err != nil {
 				return &response, err
 			}
+		} else if c.drift != nil {
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				return &response, err
+			}
+			c.drift.Check(c.driftEndpoint(req), body, v)
+			err = json.NewDecoder(bytes.NewReader(body)).Decode(v)
+			if _, mismatch := err.(*json.UnmarshalTypeError); err != nil && !mismatch {
+				return &response, err
+			}
 		} else {
 			err = json.NewDecoder(resp.Body).Decode(v)
 			if err != nil {
@@ -270,6 +320,9 @@ func prettyPrintJsonLines(b []byte) string {
 	parts := strings.Split(string(b), "\n")
 	for i, p := range parts {
 		if b := []byte(p); json.Valid(b) {
+			if redactionEnabled() {
+				b = redactJSON(b)
+			}
 			var out bytes.Buffer
 			_ = json.Indent(&out, b, "", " ")
 			parts[i] = out.String()
@@ -281,11 +334,6 @@ func prettyPrintJsonLines(b []byte) string {
 func dumpResponse(resp *http.Response) {
 	o, _ := httputil.DumpResponse(resp, true)
 	strResp := prettyPrintJsonLines(o)
-	reg, _ := regexp.Compile(`"token":(.+?),`)
-	reMatches := reg.FindStringSubmatch(strResp)
-	if len(reMatches) == 2 {
-		strResp = strings.Replace(strResp, reMatches[1], strings.Repeat("-", len(reMatches[1])), 1)
-	}
 	log.Printf("\n=======[RESPONSE]============\n%s\n\n", strResp)
 }
 
@@ -294,7 +342,7 @@ func dumpRequest(req *http.Request) {
 	r.Body, _ = req.GetBody()
 	h := r.Header
 	if len(h.Get("X-Auth-Token")) != 0 {
-		h.Set("X-Auth-Token", "**REDACTED**")
+		h.Set("X-Auth-Token", redactedValue)
 	}
 	defer r.Body.Close()
 
@@ -309,18 +357,21 @@ func dumpRequest(req *http.Request) {
 // v is the interface to unmarshal the response JSON into
 func (c *Client) DoRequest(method, path string, body, v interface{}) (*Response, error) {
 	req, err := c.NewRequest(method, path, body)
-	if c.debug {
-		dumpRequest(req)
-	}
 	if err != nil {
 		return nil, err
 	}
+	if c.debug {
+		dumpRequest(req)
+	}
 	return c.Do(req, v)
 }
 
 // DoRequestWithHeader same as DoRequest
 func (c *Client) DoRequestWithHeader(method string, headers map[string]string, path string, body, v interface{}) (*Response, error) {
 	req, err := c.NewRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
 	for k, v := range headers {
 		req.Header.Add(k, v)
 	}
@@ -328,9 +379,6 @@ func (c *Client) DoRequestWithHeader(method string, headers map[string]string, p
 	if c.debug {
 		dumpRequest(req)
 	}
-	if err != nil {
-		return nil, err
-	}
 	return c.Do(req, v)
 }
 
@@ -0,0 +1,427 @@
+package packngo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Capacity levels reported by CapacityService, from most to least available
+const (
+	CapacityLevelNormal      = "normal"
+	CapacityLevelLimited     = "limited"
+	CapacityLevelCritical    = "critical"
+	CapacityLevelUnavailable = "unavailable"
+)
+
+// capacityLevelRank orders capacity levels, higher is better. Unavailable and
+// unknown levels rank zero.
+var capacityLevelRank = map[string]int{
+	CapacityLevelNormal:   3,
+	CapacityLevelLimited:  2,
+	CapacityLevelCritical: 1,
+}
+
+// PlacementNeed is a number of devices of a plan to place
+type PlacementNeed struct {
+	Plan     string
+	Quantity int
+
+	// SpotPriceMax places spot instances when set. Metros whose current spot
+	// price exceeds it are rejected.
+	SpotPriceMax float64
+}
+
+// PlacementConstraints restrict where and how needs are placed
+type PlacementConstraints struct {
+	// Metros lists the allowed metro codes. All metros with reported capacity
+	// are allowed when empty.
+	Metros []string
+
+	// MaxHourlyCost caps the total hourly price of the placement. Zero means
+	// no limit. Metros are chosen by rank as long as the remaining needs can
+	// still be placed within the cap, and plans without on-demand pricing are
+	// rejected.
+	MaxHourlyCost float64
+
+	// Spread splits each need across at least this many metros
+	Spread int
+}
+
+// Placement is a quantity of a plan placed in a metro
+type Placement struct {
+	Plan         string  `json:"plan"`
+	Metro        string  `json:"metro"`
+	Quantity     int     `json:"quantity"`
+	Level        string  `json:"level"`
+	HourlyPrice  float64 `json:"hourly_price"`
+	Spot         bool    `json:"spot,omitempty"`
+	SpotPriceMax float64 `json:"spot_price_max,omitempty"`
+	Reason       string  `json:"reason"`
+
+	// Unpriced is set when the plan has no on-demand pricing. HourlyPrice is
+	// then zero and the placement is not included in HourlyCost.
+	Unpriced bool `json:"unpriced,omitempty"`
+}
+
+// PlacementRejection explains why a metro was not used for a plan
+type PlacementRejection struct {
+	Plan   string `json:"plan"`
+	Metro  string `json:"metro"`
+	Reason string `json:"reason"`
+}
+
+// PlacementPlan is the result of PlacementPlanner.Plan. Placements of each
+// plan are ranked from the most to the least preferred metro.
+type PlacementPlan struct {
+	Placements []Placement          `json:"placements"`
+	Rejected   []PlacementRejection `json:"rejected,omitempty"`
+	HourlyCost float64              `json:"hourly_cost"`
+}
+
+// PlacementError is returned when the needs cannot be placed within the
+// constraints. Rejected explains why each candidate metro was discarded.
+type PlacementError struct {
+	Reason   string
+	Rejected []PlacementRejection
+}
+
+func (e *PlacementError) Error() string {
+	return "placement failed: " + e.Reason
+}
+
+// BatchCreateRequest turns the placement into a batch request, one batch per
+// placement, copying the remaining fields from template
+func (p *PlacementPlan) BatchCreateRequest(template DeviceCreateRequest) *BatchCreateRequest {
+	req := &BatchCreateRequest{}
+	for _, pl := range p.Placements {
+		dcr := template
+		dcr.Plan, dcr.Metro, dcr.Facility = pl.Plan, pl.Metro, nil
+		req.Batches = append(req.Batches, BatchCreateDevice{
+			DeviceCreateRequest: dcr,
+			Quantity:            int32(pl.Quantity),
+			SpotInstance:        pl.Spot,
+			SpotPriceMax:        pl.SpotPriceMax,
+		})
+	}
+	return req
+}
+
+// PlacementPlanner ranks metros for a set of needs by capacity level and
+// hourly price, and confirms the chosen placement with CheckMetros
+//
+//	planner := NewPlacementPlanner(c.CapacityService, c.SpotMarket, c.Plans)
+//	plan, err := planner.Plan([]PlacementNeed{{Plan: "c3.small.x86", Quantity: 20}},
+//		&PlacementConstraints{Metros: []string{"sv", "da", "ny"}, Spread: 2, MaxHourlyCost: 15})
+//	batch, _, err := c.Batches.Create(projectID, plan.BatchCreateRequest(template))
+type PlacementPlanner struct {
+	capacity CapacityService
+	market   SpotMarketService
+	plans    PlanService
+}
+
+// NewPlacementPlanner uses capacity for availability, market for spot prices
+// and plans for on-demand prices
+func NewPlacementPlanner(capacity CapacityService, market SpotMarketService, plans PlanService) *PlacementPlanner {
+	return &PlacementPlanner{capacity: capacity, market: market, plans: plans}
+}
+
+type placementCandidate struct {
+	metro    string
+	level    string
+	price    float64
+	unpriced bool
+}
+
+// Plan places every need according to constraints. Candidate metros are
+// ranked by capacity level, then by hourly price, and each need is split
+// evenly across the best Spread metros that keep the placement within
+// MaxHourlyCost. Metros that CheckMetros reports as unable to fulfil their
+// share are rejected and the next candidate is tried.
+func (pp *PlacementPlanner) Plan(needs []PlacementNeed, constraints *PlacementConstraints) (*PlacementPlan, error) {
+	if constraints == nil {
+		constraints = &PlacementConstraints{}
+	}
+	spread := constraints.Spread
+	if spread < 1 {
+		spread = 1
+	}
+
+	report, _, err := pp.capacity.ListMetros()
+	if err != nil {
+		return nil, err
+	}
+	var spotPrices PriceMap
+	for _, n := range needs {
+		if n.SpotPriceMax > 0 && spotPrices == nil {
+			if spotPrices, _, err = pp.market.PricesByMetro(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	plans, _, err := pp.plans.List(nil)
+	if err != nil {
+		return nil, err
+	}
+	onDemand := map[string]float64{}
+	for _, p := range plans {
+		if p.Pricing != nil && p.Pricing.Hour > 0 {
+			onDemand[p.Slug] = float64(p.Pricing.Hour)
+		}
+	}
+
+	result := &PlacementPlan{}
+	reject := func(plan, metro, format string, args ...interface{}) {
+		result.Rejected = append(result.Rejected, PlacementRejection{Plan: plan, Metro: metro, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	candidates := make([][]placementCandidate, len(needs))
+	for i, n := range needs {
+		if n.Quantity < 1 {
+			return nil, fmt.Errorf("need %d (%s) has no quantity", i, n.Plan)
+		}
+		for _, metro := range placementMetros(*report, constraints.Metros) {
+			level := ""
+			if levels, ok := (*report)[metro]; ok {
+				level = levels[n.Plan].Level
+			}
+			if capacityLevelRank[level] == 0 {
+				reject(n.Plan, metro, "capacity level %q", level)
+				continue
+			}
+			price, priced := onDemand[n.Plan]
+			c := placementCandidate{metro: metro, level: level, price: price, unpriced: !priced}
+			if n.SpotPriceMax > 0 {
+				price, ok := spotPrices[metro][n.Plan]
+				if !ok {
+					reject(n.Plan, metro, "no spot price")
+					continue
+				}
+				if price > n.SpotPriceMax {
+					reject(n.Plan, metro, "spot price %.4f exceeds maximum %.4f", price, n.SpotPriceMax)
+					continue
+				}
+				c.price, c.unpriced = price, false
+			}
+			if c.unpriced && constraints.MaxHourlyCost > 0 {
+				reject(n.Plan, metro, "no on-demand price to check against the maximum hourly cost")
+				continue
+			}
+			candidates[i] = append(candidates[i], c)
+		}
+		sort.SliceStable(candidates[i], func(a, b int) bool {
+			ca, cb := candidates[i][a], candidates[i][b]
+			if capacityLevelRank[ca.level] != capacityLevelRank[cb.level] {
+				return capacityLevelRank[ca.level] > capacityLevelRank[cb.level]
+			}
+			if ca.price != cb.price {
+				return ca.price < cb.price
+			}
+			return ca.metro < cb.metro
+		})
+	}
+
+	quantities := make([][]int, len(needs))
+	for i, n := range needs {
+		quantities[i] = placementQuantities(n.Quantity, spread)
+	}
+
+	for {
+		minCosts := make([]float64, len(needs))
+		for i, n := range needs {
+			if len(candidates[i]) < spread {
+				return nil, &PlacementError{
+					Reason:   fmt.Sprintf("%s needs %d metros, %d candidates left", n.Plan, spread, len(candidates[i])),
+					Rejected: result.Rejected,
+				}
+			}
+			minCosts[i] = cheapestPlacementCost(candidates[i], quantities[i])
+		}
+
+		var placements []Placement
+		spent := 0.0
+		for i, n := range needs {
+			budget := math.Inf(1)
+			if constraints.MaxHourlyCost > 0 {
+				budget = constraints.MaxHourlyCost - spent
+				for _, c := range minCosts[i+1:] {
+					budget -= c
+				}
+			}
+			chosen, ok := choosePlacement(candidates[i], quantities[i], budget)
+			if !ok {
+				total := spent
+				for _, c := range minCosts[i:] {
+					total += c
+				}
+				return nil, &PlacementError{
+					Reason:   fmt.Sprintf("cheapest placement costs %.4f per hour, exceeding maximum %.4f", total, constraints.MaxHourlyCost),
+					Rejected: result.Rejected,
+				}
+			}
+			for rank, c := range chosen {
+				qty := quantities[i][rank]
+				spent += c.price * float64(qty)
+				if qty == 0 {
+					continue
+				}
+				placements = append(placements, Placement{
+					Plan:         n.Plan,
+					Metro:        c.metro,
+					Quantity:     qty,
+					Level:        c.level,
+					HourlyPrice:  c.price,
+					Spot:         n.SpotPriceMax > 0,
+					SpotPriceMax: n.SpotPriceMax,
+					Reason:       placementReason(rank, c),
+					Unpriced:     c.unpriced,
+				})
+			}
+		}
+
+		input := &CapacityInput{}
+		for _, p := range placements {
+			input.Servers = append(input.Servers, ServerInfo{Metro: p.Metro, Plan: p.Plan, Quantity: p.Quantity})
+		}
+		checked, _, err := pp.capacity.CheckMetros(input)
+		if err != nil {
+			return nil, err
+		}
+
+		unavailable := map[string]bool{}
+		for _, s := range checked.Servers {
+			if !s.Available {
+				unavailable[s.Plan+"/"+s.Metro] = true
+			}
+		}
+		if len(unavailable) == 0 {
+			result.Placements = placements
+			break
+		}
+		removed := 0
+		for i, n := range needs {
+			kept := candidates[i][:0]
+			for _, c := range candidates[i] {
+				if unavailable[n.Plan+"/"+c.metro] {
+					reject(n.Plan, c.metro, "capacity check failed")
+					removed++
+					continue
+				}
+				kept = append(kept, c)
+			}
+			candidates[i] = kept
+		}
+		if removed == 0 {
+			return nil, &PlacementError{Reason: "capacity check failed for unplanned servers", Rejected: result.Rejected}
+		}
+	}
+
+	for _, p := range result.Placements {
+		result.HourlyCost += p.HourlyPrice * float64(p.Quantity)
+	}
+	if constraints.MaxHourlyCost > 0 && result.HourlyCost > constraints.MaxHourlyCost {
+		return nil, &PlacementError{
+			Reason:   fmt.Sprintf("hourly cost %.4f exceeds maximum %.4f", result.HourlyCost, constraints.MaxHourlyCost),
+			Rejected: result.Rejected,
+		}
+	}
+	return result, nil
+}
+
+// placementMetros returns the allowed metros in a stable order
+func placementMetros(report CapacityReport, allowed []string) []string {
+	if len(allowed) > 0 {
+		metros := make([]string, len(allowed))
+		for i, m := range allowed {
+			metros[i] = strings.ToLower(m)
+		}
+		return metros
+	}
+	metros := make([]string, 0, len(report))
+	for m := range report {
+		metros = append(metros, m)
+	}
+	sort.Strings(metros)
+	return metros
+}
+
+// placementQuantities splits quantity across spread metros, larger shares
+// first
+func placementQuantities(quantity, spread int) []int {
+	quantities := make([]int, spread)
+	for rank := range quantities {
+		quantities[rank] = quantity / spread
+		if rank < quantity%spread {
+			quantities[rank]++
+		}
+	}
+	return quantities
+}
+
+// cheapestPlacementCost is the lowest hourly cost of placing quantities, in
+// decreasing order, in distinct candidate metros
+func cheapestPlacementCost(candidates []placementCandidate, quantities []int) float64 {
+	prices := make([]float64, len(candidates))
+	for i, c := range candidates {
+		prices[i] = c.price
+	}
+	sort.Float64s(prices)
+	cost := 0.0
+	for i, q := range quantities {
+		if i < len(prices) {
+			cost += prices[i] * float64(q)
+		}
+	}
+	return cost
+}
+
+// choosePlacement picks one ranked candidate per quantity, preferring the best
+// ranked candidate that still allows the remaining quantities to be placed
+// within budget. It reports false when the budget can not be met.
+func choosePlacement(candidates []placementCandidate, quantities []int, budget float64) ([]placementCandidate, bool) {
+	// tolerate rounding errors of the float sums
+	const epsilon = 1e-9
+
+	used := make([]bool, len(candidates))
+	unused := func() []placementCandidate {
+		var rest []placementCandidate
+		for i, c := range candidates {
+			if !used[i] {
+				rest = append(rest, c)
+			}
+		}
+		return rest
+	}
+
+	var chosen []placementCandidate
+	spent := 0.0
+	for k, q := range quantities {
+		found := false
+		for i, c := range candidates {
+			if used[i] {
+				continue
+			}
+			used[i] = true
+			cost := c.price * float64(q)
+			if spent+cost+cheapestPlacementCost(unused(), quantities[k+1:]) <= budget+epsilon {
+				chosen = append(chosen, c)
+				spent += cost
+				found = true
+				break
+			}
+			used[i] = false
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return chosen, true
+}
+
+func placementReason(rank int, c placementCandidate) string {
+	if c.unpriced {
+		return fmt.Sprintf("ranked %d: %s capacity, no on-demand price", rank+1, c.level)
+	}
+	return fmt.Sprintf("ranked %d: %s capacity, %.4f/hour", rank+1, c.level, c.price)
+}
@@ -0,0 +1,181 @@
+package packngo
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakePlacementCapacity struct {
+	CapacityService
+	report CapacityReport
+
+	// full lists the plan/metro pairs failing CheckMetros
+	full   map[string]bool
+	checks int
+}
+
+func (f *fakePlacementCapacity) ListMetros() (*CapacityReport, *Response, error) {
+	return &f.report, nil, nil
+}
+
+func (f *fakePlacementCapacity) CheckMetros(input *CapacityInput) (*CapacityInput, *Response, error) {
+	f.checks++
+	out := &CapacityInput{}
+	for _, s := range input.Servers {
+		s.Available = !f.full[s.Plan+"/"+s.Metro]
+		out.Servers = append(out.Servers, s)
+	}
+	return out, nil, nil
+}
+
+type fakePlacementPlans struct {
+	PlanService
+}
+
+func (fakePlacementPlans) List(*ListOptions) ([]Plan, *Response, error) {
+	return []Plan{
+		{Slug: "c3.small.x86", Pricing: &Pricing{Hour: 0.5}},
+		{Slug: "m3.large.x86", Pricing: &Pricing{Hour: 2}},
+		{Slug: "n3.xlarge.x86"},
+	}, nil, nil
+}
+
+func testPlacementPlanner(full map[string]bool) (*PlacementPlanner, *fakePlacementCapacity) {
+	capacity := &fakePlacementCapacity{
+		report: CapacityReport{
+			"sv": {"c3.small.x86": {Level: CapacityLevelLimited}, "m3.large.x86": {Level: CapacityLevelNormal}},
+			"da": {"c3.small.x86": {Level: CapacityLevelNormal}, "m3.large.x86": {Level: CapacityLevelUnavailable}},
+			"ny": {"c3.small.x86": {Level: CapacityLevelNormal}, "n3.xlarge.x86": {Level: CapacityLevelNormal}},
+		},
+		full: full,
+	}
+	market := &fakeSpotMarket{prices: []PriceMap{{
+		"sv": {"c3.small.x86": 0.1},
+		"da": {"c3.small.x86": 0.3},
+		"ny": {"c3.small.x86": 0.2},
+	}}}
+	return NewPlacementPlanner(capacity, market, fakePlacementPlans{}), capacity
+}
+
+func TestPlacementPlanner_Plan(t *testing.T) {
+	planner, _ := testPlacementPlanner(nil)
+
+	_, err := planner.Plan([]PlacementNeed{
+		{Plan: "c3.small.x86", Quantity: 5},
+		{Plan: "m3.large.x86", Quantity: 1},
+	}, &PlacementConstraints{Spread: 2, Metros: []string{"SV", "da", "ny"}})
+	if _, ok := err.(*PlacementError); !ok {
+		t.Fatalf("expected *PlacementError for m3.large.x86 spread, got %v", err)
+	}
+
+	plan, err := planner.Plan([]PlacementNeed{{Plan: "c3.small.x86", Quantity: 5}}, &PlacementConstraints{Spread: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []Placement{}
+	for _, p := range plan.Placements {
+		p.Reason = ""
+		got = append(got, p)
+	}
+	want := []Placement{
+		{Plan: "c3.small.x86", Metro: "da", Quantity: 3, Level: CapacityLevelNormal, HourlyPrice: 0.5},
+		{Plan: "c3.small.x86", Metro: "ny", Quantity: 2, Level: CapacityLevelNormal, HourlyPrice: 0.5},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Plan() mismatch (-want +got):\n%s", diff)
+	}
+	if plan.HourlyCost != 2.5 {
+		t.Errorf("HourlyCost = %v, want 2.5", plan.HourlyCost)
+	}
+
+	if _, err := planner.Plan([]PlacementNeed{{Plan: "c3.small.x86", Quantity: 5}}, &PlacementConstraints{MaxHourlyCost: 2}); err == nil {
+		t.Error("expected cost limit error")
+	}
+}
+
+func TestPlacementPlanner_PlanSpot(t *testing.T) {
+	planner, _ := testPlacementPlanner(nil)
+
+	plan, err := planner.Plan([]PlacementNeed{{Plan: "c3.small.x86", Quantity: 2, SpotPriceMax: 0.25}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Placements) != 1 || plan.Placements[0].Metro != "ny" || plan.Placements[0].HourlyPrice != 0.2 || !plan.Placements[0].Spot {
+		t.Fatalf("unexpected placements %+v", plan.Placements)
+	}
+
+	var rejected []string
+	for _, r := range plan.Rejected {
+		rejected = append(rejected, r.Metro)
+	}
+	if diff := cmp.Diff([]string{"da"}, rejected); diff != "" {
+		t.Errorf("rejected metros mismatch (-want +got):\n%s", diff)
+	}
+
+	batch := plan.BatchCreateRequest(DeviceCreateRequest{OS: "ubuntu_20_04", Facility: []string{"sv15"}})
+	b := batch.Batches[0]
+	if b.Plan != "c3.small.x86" || b.Metro != "ny" || b.Facility != nil || b.Quantity != 2 || !b.SpotInstance || b.SpotPriceMax != 0.25 || b.OS != "ubuntu_20_04" {
+		t.Errorf("unexpected batch %+v", b)
+	}
+}
+
+func TestPlacementPlanner_PlanMaxHourlyCost(t *testing.T) {
+	planner, _ := testPlacementPlanner(nil)
+
+	// ny ranks first with normal capacity, but only the cheaper limited sv
+	// fits the cost cap
+	plan, err := planner.Plan([]PlacementNeed{{Plan: "c3.small.x86", Quantity: 10, SpotPriceMax: 0.25}}, &PlacementConstraints{MaxHourlyCost: 1.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Placements) != 1 || plan.Placements[0].Metro != "sv" || plan.HourlyCost != 1 {
+		t.Errorf("unexpected placements %+v costing %v", plan.Placements, plan.HourlyCost)
+	}
+
+	// the ranked ny is kept for the first share as sv fits the rest
+	plan, err = planner.Plan([]PlacementNeed{{Plan: "c3.small.x86", Quantity: 10, SpotPriceMax: 0.25}}, &PlacementConstraints{MaxHourlyCost: 1.5, Spread: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Placements) != 2 || plan.Placements[0].Metro != "ny" || plan.Placements[1].Metro != "sv" || plan.HourlyCost != 1.5 {
+		t.Errorf("unexpected placements %+v costing %v", plan.Placements, plan.HourlyCost)
+	}
+
+	if _, err := planner.Plan([]PlacementNeed{{Plan: "c3.small.x86", Quantity: 10, SpotPriceMax: 0.25}}, &PlacementConstraints{MaxHourlyCost: 0.9}); err == nil {
+		t.Error("expected cost limit error")
+	}
+}
+
+func TestPlacementPlanner_PlanUnpriced(t *testing.T) {
+	planner, _ := testPlacementPlanner(nil)
+
+	plan, err := planner.Plan([]PlacementNeed{{Plan: "n3.xlarge.x86", Quantity: 1}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Placements) != 1 || !plan.Placements[0].Unpriced || plan.HourlyCost != 0 {
+		t.Errorf("expected an unpriced placement, got %+v", plan.Placements)
+	}
+
+	_, err = planner.Plan([]PlacementNeed{{Plan: "n3.xlarge.x86", Quantity: 1}}, &PlacementConstraints{MaxHourlyCost: 100})
+	if _, ok := err.(*PlacementError); !ok {
+		t.Errorf("expected *PlacementError for an unpriced plan under a cost cap, got %v", err)
+	}
+}
+
+func TestPlacementPlanner_PlanCapacityCheck(t *testing.T) {
+	planner, capacity := testPlacementPlanner(map[string]bool{"c3.small.x86/da": true})
+
+	plan, err := planner.Plan([]PlacementNeed{{Plan: "c3.small.x86", Quantity: 4}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity.checks != 2 || plan.Placements[0].Metro != "ny" {
+		t.Errorf("expected ny after failed da check, got %+v after %d checks", plan.Placements, capacity.checks)
+	}
+	last := plan.Rejected[len(plan.Rejected)-1]
+	if last.Metro != "da" || last.Reason != "capacity check failed" {
+		t.Errorf("unexpected rejection %+v", last)
+	}
+}
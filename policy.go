@@ -0,0 +1,399 @@
+package packngo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyRequest is a mutating request evaluated by a Policy
+type PolicyRequest struct {
+	Method string
+
+	// Path is the API path without the query
+	Path  string
+	Query url.Values
+
+	// Body is the typed request, e.g. *DeviceCreateRequest or
+	// *DeviceDeleteRequest. It is nil for requests without a body.
+	Body interface{}
+
+	projectID       string
+	projectResolved bool
+	resolveProject  func(resourcePath string) (string, error)
+
+	fields interface{}
+}
+
+// ProjectID returns the project the request applies to. It is taken from a
+// /projects/{id} path or the project_id of the body, or else looked up by
+// fetching the resource, e.g. the device of DELETE /devices/{id}. It is empty
+// when the request does not apply to a project.
+func (r *PolicyRequest) ProjectID() (string, error) {
+	if r.projectResolved {
+		return r.projectID, nil
+	}
+
+	parts := strings.Split(strings.Trim(r.Path, "/"), "/")
+	switch {
+	case len(parts) > 1 && "/"+parts[0] == projectBasePath:
+		r.projectID = parts[1]
+	case r.field("project_id") != "":
+		r.projectID = r.field("project_id")
+	case len(parts) > 1 && ValidateUUID(parts[1]) == nil && r.resolveProject != nil:
+		id, err := r.resolveProject("/" + path.Join(parts[0], parts[1]))
+		if err != nil {
+			return "", err
+		}
+		r.projectID = id
+	}
+	r.projectResolved = true
+	return r.projectID, nil
+}
+
+// Fields returns the body decoded as generic JSON
+func (r *PolicyRequest) Fields() interface{} {
+	if r.fields == nil && r.Body != nil {
+		b, err := json.Marshal(r.Body)
+		if err == nil {
+			_ = json.Unmarshal(b, &r.fields)
+		}
+	}
+	return r.fields
+}
+
+// field returns a top-level string field of the body
+func (r *PolicyRequest) field(name string) string {
+	m, _ := r.Fields().(map[string]interface{})
+	s, _ := m[name].(string)
+	return s
+}
+
+// PolicyViolation describes why a request was refused
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+
+	// Field is the offending request field, if any
+	Field string `json:"field,omitempty"`
+}
+
+// PolicyError is returned by Client.NewRequest, and so by every service
+// method, for requests violating a policy. The request is not sent.
+type PolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = fmt.Sprintf("%s: %s", v.Rule, v.Message)
+	}
+	return fmt.Sprintf("%s %s violates policy: %s", e.Violations[0].Method, e.Violations[0].Path, strings.Join(msgs, "; "))
+}
+
+// Policy evaluates mutating requests
+type Policy interface {
+	Evaluate(r *PolicyRequest) ([]PolicyViolation, error)
+}
+
+// PolicyFunc is a Policy written in Go
+type PolicyFunc func(r *PolicyRequest) ([]PolicyViolation, error)
+
+// Evaluate calls f
+func (f PolicyFunc) Evaluate(r *PolicyRequest) ([]PolicyViolation, error) {
+	return f(r)
+}
+
+// WithPolicy evaluates policies before every POST, PUT, PATCH and DELETE
+// request. Requests with violations fail with a *PolicyError.
+func WithPolicy(policies ...Policy) ClientOpt {
+	return func(c *Client) error {
+		c.policies = append(c.policies, policies...)
+
+		return nil
+	}
+}
+
+// checkPolicies evaluates the policies of c against a request
+func (c *Client) checkPolicies(method, apiPath string, body interface{}) error {
+	if len(c.policies) == 0 {
+		return nil
+	}
+	switch method {
+	case "POST", "PUT", "PATCH", "DELETE":
+	default:
+		return nil
+	}
+
+	u, err := url.Parse(apiPath)
+	if err != nil {
+		return err
+	}
+	r := &PolicyRequest{
+		Method: method,
+		Path:   "/" + strings.TrimPrefix(u.Path, "/"),
+		Query:  u.Query(),
+		Body:   body,
+		resolveProject: func(resourcePath string) (string, error) {
+			resource := new(struct {
+				Project   *Href  `json:"project"`
+				ProjectID string `json:"project_id"`
+			})
+			if _, err := c.DoRequest("GET", resourcePath, nil, resource); err != nil {
+				return "", err
+			}
+			if resource.Project != nil && resource.Project.Href != "" {
+				return path.Base(resource.Project.Href), nil
+			}
+			return resource.ProjectID, nil
+		},
+	}
+
+	var violations []PolicyViolation
+	for _, p := range c.policies {
+		v, err := p.Evaluate(r)
+		if err != nil {
+			return fmt.Errorf("evaluating policy for %s %s: %w", r.Method, r.Path, err)
+		}
+		violations = append(violations, v...)
+	}
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// PolicyRule is a declarative Policy, written in Go or loaded from YAML with
+// LoadPolicyFile. A rule applies to requests matching all of its non-empty
+// Methods, Types, Paths and Projects, and reports a violation for each
+// constraint the request breaks.
+type PolicyRule struct {
+	Name    string `yaml:"name"`
+	Message string `yaml:"message,omitempty"`
+
+	// Methods are HTTP methods, e.g. DELETE
+	Methods []string `yaml:"methods,omitempty"`
+
+	// Types are request type names, e.g. DeviceCreateRequest
+	Types []string `yaml:"types,omitempty"`
+
+	// Paths are path.Match patterns, e.g. /devices/*
+	Paths []string `yaml:"paths,omitempty"`
+
+	// Projects are project IDs. Requests whose project can not be determined
+	// match, so that the rule is not bypassed.
+	Projects []string `yaml:"projects,omitempty"`
+
+	// Deny refuses every matching request
+	Deny bool `yaml:"deny,omitempty"`
+
+	// AllowedPlans lists the plans allowed in "plan" fields of the body
+	AllowedPlans []string `yaml:"allowed_plans,omitempty"`
+
+	// RequiredTags must be present on every body object with a "plan" or
+	// "tags" field. A tag "owner" is satisfied by "owner" itself or any tag
+	// beginning with "owner:" or "owner=".
+	RequiredTags []string `yaml:"required_tags,omitempty"`
+
+	// DeniedFields refuses bodies where any of the named fields, at any
+	// depth, has the given value, e.g. force_delete: true
+	DeniedFields map[string]interface{} `yaml:"denied_fields,omitempty"`
+}
+
+// Evaluate checks r against the rule
+func (rule *PolicyRule) Evaluate(r *PolicyRequest) ([]PolicyViolation, error) {
+	if ok, err := rule.matches(r); !ok || err != nil {
+		return nil, err
+	}
+
+	var violations []PolicyViolation
+	violate := func(field, msg string) {
+		if rule.Message != "" {
+			msg = rule.Message
+		}
+		violations = append(violations, PolicyViolation{Rule: rule.Name, Message: msg, Method: r.Method, Path: r.Path, Field: field})
+	}
+
+	if rule.Deny {
+		violate("", "request denied")
+	}
+	deniedFields := make([]string, 0, len(rule.DeniedFields))
+	for field := range rule.DeniedFields {
+		deniedFields = append(deniedFields, field)
+	}
+	sort.Strings(deniedFields)
+
+	objects := policyObjects(r.Fields(), nil)
+	for _, obj := range objects {
+		if plan, ok := obj["plan"].(string); ok && len(rule.AllowedPlans) > 0 && !contains(rule.AllowedPlans, plan) {
+			violate("plan", fmt.Sprintf("plan %q is not allowed", plan))
+		}
+
+		_, hasPlan := obj["plan"]
+		tags, hasTags := obj["tags"]
+		if len(rule.RequiredTags) > 0 && (hasPlan || hasTags) {
+			for _, required := range rule.RequiredTags {
+				if !policyHasTag(tags, required) {
+					violate("tags", fmt.Sprintf("tag %q is required", required))
+				}
+			}
+		}
+
+		for _, field := range deniedFields {
+			if v, ok := obj[field]; ok && reflect.DeepEqual(v, normalizePolicyValue(rule.DeniedFields[field])) {
+				violate(field, fmt.Sprintf("%s=%v is not allowed", field, v))
+			}
+		}
+	}
+	return violations, nil
+}
+
+func (rule *PolicyRule) matches(r *PolicyRequest) (bool, error) {
+	if len(rule.Methods) > 0 {
+		found := false
+		for _, m := range rule.Methods {
+			found = found || strings.EqualFold(m, r.Method)
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	if len(rule.Types) > 0 {
+		t := reflect.TypeOf(r.Body)
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || !contains(rule.Types, t.Name()) {
+			return false, nil
+		}
+	}
+	if len(rule.Paths) > 0 {
+		found := false
+		for _, pattern := range rule.Paths {
+			ok, _ := path.Match(pattern, r.Path)
+			found = found || ok
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	if len(rule.Projects) > 0 {
+		projectID, err := r.ProjectID()
+		if err != nil {
+			return false, err
+		}
+		if projectID != "" && !contains(rule.Projects, projectID) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// policyObjects returns the JSON objects of v, at any depth
+func policyObjects(v interface{}, objects []map[string]interface{}) []map[string]interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		objects = append(objects, v)
+		for _, child := range v {
+			objects = policyObjects(child, objects)
+		}
+	case []interface{}:
+		for _, child := range v {
+			objects = policyObjects(child, objects)
+		}
+	}
+	return objects
+}
+
+func policyHasTag(tags interface{}, required string) bool {
+	list, _ := tags.([]interface{})
+	for _, t := range list {
+		tag, _ := t.(string)
+		if tag == required || strings.HasPrefix(tag, required+":") || strings.HasPrefix(tag, required+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePolicyValue converts a YAML or Go value to its generic JSON form
+func normalizePolicyValue(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// PolicySet is a list of rules, the format of policy files:
+//
+//	rules:
+//	- name: no-deletes-in-production
+//	  methods: [DELETE]
+//	  projects: [3b1a9fb1-...]
+//	  deny: true
+//	- name: owner-tag
+//	  types: [DeviceCreateRequest, BatchCreateRequest]
+//	  required_tags: [owner]
+type PolicySet struct {
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// Evaluate checks r against every rule
+func (s *PolicySet) Evaluate(r *PolicyRequest) ([]PolicyViolation, error) {
+	var violations []PolicyViolation
+	for i := range s.Rules {
+		v, err := s.Rules[i].Evaluate(r)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, v...)
+	}
+	return violations, nil
+}
+
+// ParsePolicy parses a YAML policy. Unknown keys are errors.
+func ParsePolicy(data []byte) (*PolicySet, error) {
+	s := new(PolicySet)
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("parsing policy: %w", err)
+	}
+	for i, rule := range s.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("policy rule %d has no name", i)
+		}
+		for _, pattern := range rule.Paths {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("policy rule %s: path %q: %w", rule.Name, pattern, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// LoadPolicyFile reads a YAML policy file
+func LoadPolicyFile(name string) (*PolicySet, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(data)
+}
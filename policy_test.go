@@ -0,0 +1,203 @@
+package packngo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testPolicyProduction = "5b1a9fb1-61b1-4b5b-8d3c-3b1a9fb1c001"
+	testPolicyDevice     = "5b1a9fb1-61b1-4b5b-8d3c-3b1a9fb1d001"
+)
+
+const testPolicyYAML = `
+rules:
+- name: no-deletes-in-production
+  methods: [DELETE]
+  projects: [` + testPolicyProduction + `]
+  deny: true
+- name: plan-allowlist
+  types: [DeviceCreateRequest, BatchCreateRequest]
+  allowed_plans: [c3.small.x86]
+- name: owner-tag
+  methods: [POST]
+  paths: [/projects/*/devices, /projects/*/devices/batch]
+  required_tags: [owner]
+- name: no-force-delete
+  denied_fields:
+    force_delete: true
+  message: force deletes are not allowed
+`
+
+func testPolicyClient(t *testing.T, policies ...Policy) (*Client, *[]string) {
+	var sent []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == "GET":
+			fmt.Fprintf(w, `{"id": %q, "project": {"href": "/metal/v1/projects/%s"}}`, testPolicyDevice, testPolicyProduction)
+		case r.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	c, err := NewClient(WithAuth("packngo test", "token"), WithBaseURL(ts.URL+"/"), WithPolicy(policies...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, &sent
+}
+
+func TestParsePolicy(t *testing.T) {
+	if _, err := ParsePolicy([]byte("rules:\n- name: x\n  unknown: true\n")); err == nil {
+		t.Error("expected unknown key error")
+	}
+	if _, err := ParsePolicy([]byte("rules:\n- deny: true\n")); err == nil {
+		t.Error("expected missing name error")
+	}
+	if _, err := ParsePolicy([]byte("rules:\n- name: x\n  paths: ['[']\n")); err == nil {
+		t.Error("expected bad pattern error")
+	}
+
+	name := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(name, []byte(testPolicyYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadPolicyFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Rules) != 4 || s.Rules[3].DeniedFields["force_delete"] != true {
+		t.Errorf("unexpected policy %+v", s)
+	}
+}
+
+func TestPolicy_DeviceCreate(t *testing.T) {
+	s, err := ParsePolicy([]byte(testPolicyYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, sent := testPolicyClient(t, s)
+
+	_, _, err = c.Devices.Create(&DeviceCreateRequest{ProjectID: testPolicyProduction, Plan: "m3.large.x86", Tags: []string{"web"}})
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected PolicyError, got %v", err)
+	}
+	path := "/projects/" + testPolicyProduction + "/devices"
+	expected := []PolicyViolation{
+		{Rule: "plan-allowlist", Message: `plan "m3.large.x86" is not allowed`, Method: "POST", Path: path, Field: "plan"},
+		{Rule: "owner-tag", Message: `tag "owner" is required`, Method: "POST", Path: path, Field: "tags"},
+	}
+	if diff := cmp.Diff(expected, policyErr.Violations); diff != "" {
+		t.Error(diff)
+	}
+
+	if _, _, err := c.Devices.Create(&DeviceCreateRequest{ProjectID: testPolicyProduction, Plan: "c3.small.x86", Tags: []string{"owner:ops"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// every batch is checked
+	_, _, err = c.Batches.Create(testPolicyProduction, &BatchCreateRequest{Batches: []BatchCreateDevice{
+		{DeviceCreateRequest: DeviceCreateRequest{Plan: "c3.small.x86", Tags: []string{"owner=ops"}}},
+		{DeviceCreateRequest: DeviceCreateRequest{Plan: "c3.small.x86"}},
+	}})
+	if !errors.As(err, &policyErr) || len(policyErr.Violations) != 1 || policyErr.Violations[0].Rule != "owner-tag" {
+		t.Errorf("expected owner-tag violation, got %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"POST " + path}, *sent); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestPolicy_DeviceDelete(t *testing.T) {
+	s, err := ParsePolicy([]byte(testPolicyYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Rules[0].Projects = []string{"some-other-project"}
+	c, sent := testPolicyClient(t, s)
+
+	_, err = c.Devices.Delete(testPolicyDevice, true)
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || len(policyErr.Violations) != 1 {
+		t.Fatalf("expected a PolicyError, got %v", err)
+	}
+	if v := policyErr.Violations[0]; v.Rule != "no-force-delete" || v.Field != "force_delete" || v.Message != "force deletes are not allowed" {
+		t.Errorf("unexpected violation %+v", v)
+	}
+
+	if _, err := c.Devices.Delete(testPolicyDevice, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the device project is looked up for every delete to match the rule
+	s.Rules[0].Projects = []string{testPolicyProduction}
+	if _, err = c.Devices.Delete(testPolicyDevice, false); !errors.As(err, &policyErr) || policyErr.Violations[0].Rule != "no-deletes-in-production" {
+		t.Errorf("expected no-deletes-in-production violation, got %v", err)
+	}
+
+	expected := []string{
+		"GET /devices/" + testPolicyDevice,
+		"GET /devices/" + testPolicyDevice,
+		"DELETE /devices/" + testPolicyDevice,
+		"GET /devices/" + testPolicyDevice,
+	}
+	if diff := cmp.Diff(expected, *sent); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestPolicyFunc(t *testing.T) {
+	noReboots := PolicyFunc(func(r *PolicyRequest) ([]PolicyViolation, error) {
+		if a, ok := r.Body.(*DeviceActionRequest); ok && a.Type == "reboot" {
+			return []PolicyViolation{{Rule: "no-reboots", Message: "reboots are not allowed", Method: r.Method, Path: r.Path}}, nil
+		}
+		return nil, nil
+	})
+	c, sent := testPolicyClient(t, noReboots)
+
+	_, err := c.Devices.Reboot(testPolicyDevice)
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || policyErr.Violations[0].Rule != "no-reboots" {
+		t.Errorf("expected no-reboots violation, got %v", err)
+	}
+	if _, err := c.Devices.PowerOff(testPolicyDevice); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sent) != 1 {
+		t.Errorf("expected only the power off to be sent, got %v", *sent)
+	}
+}
+
+func TestPolicyRule_UnknownProject(t *testing.T) {
+	rule := &PolicyRule{Name: "no-deletes-in-production", Methods: []string{"DELETE"}, Projects: []string{testPolicyProduction}, Deny: true}
+
+	// the project of the key can not be determined, so the rule applies
+	violations, err := rule.Evaluate(&PolicyRequest{Method: "DELETE", Path: "/ssh-keys/" + testPolicyDevice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].Rule != "no-deletes-in-production" {
+		t.Errorf("expected no-deletes-in-production violation, got %+v", violations)
+	}
+
+	violations, err = rule.Evaluate(&PolicyRequest{Method: "DELETE", Path: "/projects/some-other-project/ssh-keys"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("unexpected violations %+v", violations)
+	}
+}
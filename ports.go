@@ -74,6 +74,9 @@ type Port struct {
 
 	// Bond details for ports with a NetworkPort type
 	Bond *BondData `json:"bond,omitempty"`
+
+	// Extra holds the fields returned by the API that are not modeled above
+	Extra ExtraFields `json:"-"`
 }
 
 type AddressRequest struct {
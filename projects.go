@@ -37,6 +37,9 @@ type Project struct {
 	URL             string        `json:"href,omitempty"`
 	PaymentMethod   PaymentMethod `json:"payment_method,omitempty"`
 	BackendTransfer bool          `json:"backend_transfer_enabled"`
+
+	// Extra holds the fields returned by the API that are not modeled above
+	Extra ExtraFields `json:"-"`
 }
 
 // BGPDiscoverResponse struct is returned from the bgp/discover endpoint
@@ -0,0 +1,67 @@
+package packngo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrAPIKeyNotFound is returned by FindAPIKey when no listed key matches the
+// token
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// ReadOnlyError is returned by NewRequest and Do for non-GET requests of a
+// Client configured WithReadOnly. The request is not sent.
+type ReadOnlyError struct {
+	Method string
+	Path   string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s %s refused by read-only client", e.Method, e.Path)
+}
+
+// WithReadOnly configures Client to refuse every request other than GET,
+// whatever the permissions of its APIKey
+func WithReadOnly() ClientOpt {
+	return func(c *Client) error {
+		c.readOnly = true
+
+		return nil
+	}
+}
+
+// FindAPIKey returns the key with the given token from the user keys and the
+// keys of the given projects. Listing user keys with a project key fails, so
+// list errors are only returned when no key matches.
+func FindAPIKey(s APIKeyService, token string, projectIDs ...string) (*APIKey, error) {
+	keys, _, listErr := s.UserList(nil)
+	for _, projectID := range projectIDs {
+		projectKeys, _, err := s.ProjectList(projectID, nil)
+		if err != nil {
+			listErr = err
+			continue
+		}
+		keys = append(keys, projectKeys...)
+	}
+
+	for i := range keys {
+		if keys[i].Token == token {
+			return &keys[i], nil
+		}
+	}
+	if listErr != nil {
+		return nil, listErr
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+// APIKeyReadOnly reports whether the APIKey of c is a read-only key. Pass the
+// project ID of project keys. Tools needing only read access can use it to
+// warn when given a read-write key.
+func (c *Client) APIKeyReadOnly(projectIDs ...string) (bool, error) {
+	key, err := FindAPIKey(c.APIKeys, c.APIKey, projectIDs...)
+	if err != nil {
+		return false, err
+	}
+	return key.ReadOnly, nil
+}
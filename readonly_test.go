@@ -0,0 +1,92 @@
+package packngo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithReadOnly(t *testing.T) {
+	var sent []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Method+" "+r.URL.Path)
+		fmt.Fprint(w, `{"id": "plan"}`)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(WithAuth("packngo test", "token"), WithBaseURL(ts.URL+"/"), WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var readOnlyErr *ReadOnlyError
+	if _, err := c.Devices.Delete(testPolicyDevice, false); !errors.As(err, &readOnlyErr) || readOnlyErr.Method != "DELETE" {
+		t.Errorf("expected ReadOnlyError, got %v", err)
+	}
+
+	req, err := http.NewRequest("POST", ts.URL+"/projects", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Do(req, nil); !errors.As(err, &readOnlyErr) || readOnlyErr.Path != "/projects" {
+		t.Errorf("expected ReadOnlyError, got %v", err)
+	}
+
+	if _, err := c.DoRequest("GET", "/plans/plan", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0] != "GET /plans/plan" {
+		t.Errorf("expected only the GET to be sent, got %v", sent)
+	}
+}
+
+type fakeAPIKeys struct {
+	APIKeyService
+	user    []APIKey
+	userErr error
+	project map[string][]APIKey
+}
+
+func (f *fakeAPIKeys) UserList(*ListOptions) ([]APIKey, *Response, error) {
+	return f.user, nil, f.userErr
+}
+
+func (f *fakeAPIKeys) ProjectList(projectID string, opts *ListOptions) ([]APIKey, *Response, error) {
+	keys, ok := f.project[projectID]
+	if !ok {
+		return nil, nil, errBoom
+	}
+	return keys, nil, nil
+}
+
+func TestFindAPIKey(t *testing.T) {
+	f := &fakeAPIKeys{
+		user:    []APIKey{{ID: "u1", Token: "user-rw"}, {ID: "u2", Token: "user-ro", ReadOnly: true}},
+		project: map[string][]APIKey{"p1": {{ID: "k1", Token: "project-ro", ReadOnly: true}}},
+	}
+
+	key, err := FindAPIKey(f, "user-ro")
+	if err != nil || key.ID != "u2" || !key.ReadOnly {
+		t.Errorf("unexpected key %+v, %v", key, err)
+	}
+	if _, err := FindAPIKey(f, "nope"); err != ErrAPIKeyNotFound {
+		t.Errorf("expected ErrAPIKeyNotFound, got %v", err)
+	}
+
+	// project keys can not list user keys
+	f.userErr = errBoom
+	key, err = FindAPIKey(f, "project-ro", "p1")
+	if err != nil || key.ID != "k1" {
+		t.Errorf("unexpected key %+v, %v", key, err)
+	}
+	if _, err := FindAPIKey(f, "nope", "p1"); err != errBoom {
+		t.Errorf("expected errBoom, got %v", err)
+	}
+
+	c := &Client{APIKey: "user-rw", APIKeys: &fakeAPIKeys{user: f.user}}
+	if readOnly, err := c.APIKeyReadOnly(); err != nil || readOnly {
+		t.Errorf("expected read-write key, got %v, %v", readOnly, err)
+	}
+}
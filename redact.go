@@ -0,0 +1,124 @@
+package packngo
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// redactedValue replaces secrets in output
+const redactedValue = "**REDACTED**"
+
+// redaction holds the JSON field names of secrets and whether they are
+// redacted
+var redaction = struct {
+	sync.RWMutex
+	disabled bool
+	fields   map[string]bool
+}{
+	fields: map[string]bool{
+		"md5":           true,
+		"md5_password":  true,
+		"password":      true,
+		"private_key":   true,
+		"root_password": true,
+		"secret":        true,
+		"token":         true,
+	},
+}
+
+// RedactField registers the JSON field name of a secret, such as
+// "root_password". Secrets are replaced by "**REDACTED**" in Stringify and
+// String output, debug dumps of requests and responses, audit records and
+// Redact results.
+func RedactField(name string) {
+	redaction.Lock()
+	defer redaction.Unlock()
+	redaction.fields[name] = true
+}
+
+// SetRedaction enables or disables the redaction of secrets. It is enabled
+// by default. Disable it only to debug; audit records are always redacted.
+func SetRedaction(enabled bool) {
+	redaction.Lock()
+	defer redaction.Unlock()
+	redaction.disabled = !enabled
+}
+
+func redactionEnabled() bool {
+	redaction.RLock()
+	defer redaction.RUnlock()
+	return !redaction.disabled
+}
+
+func isRedactedField(name string) bool {
+	redaction.RLock()
+	defer redaction.RUnlock()
+	return redaction.fields[name]
+}
+
+// redactStructField reports whether the value of struct field f is a secret
+func redactStructField(f reflect.StructField) bool {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		name = f.Name
+	}
+	return isRedactedField(name)
+}
+
+// Redact returns v as generic JSON with secrets redacted, for use with
+// structured loggers. It returns v itself when redaction is disabled or v
+// can not be encoded.
+func Redact(v interface{}) interface{} {
+	if !redactionEnabled() {
+		return v
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return redactValue(out)
+}
+
+// redactJSON replaces the values of secret fields, at any depth, regardless
+// of SetRedaction. Bodies that are not JSON are returned as a JSON string.
+func redactJSON(b []byte) json.RawMessage {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		s, _ := json.Marshal(string(b))
+		return s
+	}
+	redacted, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if isRedactedField(k) && child != nil && child != "" {
+				v[k] = redactedValue
+				continue
+			}
+			v[k] = redactValue(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(child)
+		}
+	}
+	return v
+}
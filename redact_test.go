@@ -0,0 +1,81 @@
+package packngo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStringify_Redacted(t *testing.T) {
+	d := Device{ID: "dev", RootPassword: "s3cret"}
+	k := APIKey{ID: "key", Token: "t0k3n"}
+	n := BGPNeighbor{CustomerAs: 65000, Md5Password: "md5s3cret"}
+	pw := "p4ss"
+	u := UserUpdateRequest{Password: &pw}
+
+	for _, s := range []string{d.String(), fmt.Sprintf("%v", k), fmt.Sprintf("%+v", n), fmt.Sprint(u), Stringify([]Device{d})} {
+		if strings.Contains(s, "s3cret") || strings.Contains(s, "t0k3n") || strings.Contains(s, "p4ss") {
+			t.Errorf("secret leaked in %s", s)
+		}
+		if !strings.Contains(s, redactedValue) {
+			t.Errorf("expected %s in %s", redactedValue, s)
+		}
+	}
+
+	// empty secrets are shown as empty
+	if s := (Device{ID: "dev"}).String(); strings.Contains(s, redactedValue) {
+		t.Errorf("unexpected redaction in %s", s)
+	}
+
+	SetRedaction(false)
+	defer SetRedaction(true)
+	if s := d.String(); !strings.Contains(s, `RootPassword:"s3cret"`) {
+		t.Errorf("expected the password with redaction disabled, got %s", s)
+	}
+}
+
+func TestStringify_RedactedMD5(t *testing.T) {
+	config := BGPConfig{ID: "bgp", Asn: 65000, Md5: "md5s3cret"}
+	vc := VCCreateRequest{VRFID: "vrf", PeerASN: 65100, MD5: "vcs3cret"}
+
+	b, err := json.Marshal(vc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{Stringify(config), Stringify(vc), string(redactJSON(b)), prettyPrintJsonLines(b)} {
+		if strings.Contains(s, "md5s3cret") || strings.Contains(s, "vcs3cret") {
+			t.Errorf("secret leaked in %s", s)
+		}
+		if !strings.Contains(s, redactedValue) {
+			t.Errorf("expected %s in %s", redactedValue, s)
+		}
+	}
+}
+
+func TestRedactField(t *testing.T) {
+	RedactField("customdata")
+	defer func() {
+		redaction.Lock()
+		delete(redaction.fields, "customdata")
+		redaction.Unlock()
+	}()
+
+	r := DeviceCreateRequest{Hostname: "web", CustomData: "private"}
+	if s := r.String(); strings.Contains(s, "private") {
+		t.Errorf("secret leaked in %s", s)
+	}
+
+	v := Redact(map[string]interface{}{"hostname": "web", "customdata": "private", "bgp": []BGPNeighbor{{Md5Password: "md5s3cret"}}})
+	if s := fmt.Sprint(v); strings.Contains(s, "private") || strings.Contains(s, "md5s3cret") || !strings.Contains(s, "web") {
+		t.Errorf("unexpected Redact result %s", s)
+	}
+}
+
+func TestPrettyPrintJsonLines_Redacted(t *testing.T) {
+	dump := "HTTP/1.1 201 Created\n" + `{"id": "key", "token": "t0k3n", "project": {"token": "t0k3n2"}}`
+	s := prettyPrintJsonLines([]byte(dump))
+	if strings.Contains(s, "t0k3n") || !strings.Contains(s, "HTTP/1.1 201 Created") {
+		t.Errorf("unexpected dump %s", s)
+	}
+}
@@ -0,0 +1,428 @@
+package packngo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// SpotPriceSampleInterval is the default interval between samples taken by
+	// SpotBidAdvisor.Run
+	SpotPriceSampleInterval = 5 * time.Minute
+
+	// SpotBidInterruptionTolerance is the default fraction of time the spot
+	// price may exceed a recommended bid
+	SpotBidInterruptionTolerance = 0.05
+)
+
+// SpotPriceSample is the spot price of a plan in a metro at a point in time
+type SpotPriceSample struct {
+	Time  time.Time `json:"time"`
+	Metro string    `json:"metro"`
+	Plan  string    `json:"plan"`
+	Price float64   `json:"price"`
+}
+
+// SpotPriceStore persists spot price samples
+type SpotPriceStore interface {
+	// Append adds samples to the store
+	Append([]SpotPriceSample) error
+
+	// Samples returns the samples of metro and plan taken at or after since,
+	// in chronological order. Empty metro or plan match any.
+	Samples(metro, plan string, since time.Time) ([]SpotPriceSample, error)
+}
+
+func matchSpotPriceSample(s SpotPriceSample, metro, plan string, since time.Time) bool {
+	return (metro == "" || s.Metro == metro) && (plan == "" || s.Plan == plan) && !s.Time.Before(since)
+}
+
+func sortSpotPriceSamples(samples []SpotPriceSample) {
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+}
+
+// MemorySpotPriceStore keeps samples in memory
+type MemorySpotPriceStore struct {
+	mu      sync.Mutex
+	samples []SpotPriceSample
+}
+
+var _ SpotPriceStore = (*MemorySpotPriceStore)(nil)
+
+// NewMemorySpotPriceStore returns an empty in-memory store
+func NewMemorySpotPriceStore() *MemorySpotPriceStore {
+	return &MemorySpotPriceStore{}
+}
+
+// Append adds samples to the store
+func (m *MemorySpotPriceStore) Append(samples []SpotPriceSample) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, samples...)
+	return nil
+}
+
+// Samples returns the matching samples in chronological order
+func (m *MemorySpotPriceStore) Samples(metro, plan string, since time.Time) ([]SpotPriceSample, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []SpotPriceSample
+	for _, s := range m.samples {
+		if matchSpotPriceSample(s, metro, plan, since) {
+			out = append(out, s)
+		}
+	}
+	sortSpotPriceSamples(out)
+	return out, nil
+}
+
+// FileSpotPriceStore appends samples to a file as JSON lines, so that the
+// time series survives restarts and can be shared between processes
+type FileSpotPriceStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+var _ SpotPriceStore = (*FileSpotPriceStore)(nil)
+
+// NewFileSpotPriceStore stores samples in the file at path, which is created
+// on the first Append
+func NewFileSpotPriceStore(path string) *FileSpotPriceStore {
+	return &FileSpotPriceStore{path: path}
+}
+
+// Append writes samples to the end of the file
+func (f *FileSpotPriceStore) Append(samples []SpotPriceSample) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, s := range samples {
+		if err := enc.Encode(s); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Samples reads the matching samples from the file in chronological order. A
+// missing file holds no samples.
+func (f *FileSpotPriceStore) Samples(metro, plan string, since time.Time) ([]SpotPriceSample, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var out []SpotPriceSample
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var s SpotPriceSample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", f.path, line, err)
+		}
+		if matchSpotPriceSample(s, metro, plan, since) {
+			out = append(out, s)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sortSpotPriceSamples(out)
+	return out, nil
+}
+
+// SpotPriceStats summarizes the spot price of a plan in a metro
+type SpotPriceStats struct {
+	Metro   string    `json:"metro"`
+	Plan    string    `json:"plan"`
+	Samples int       `json:"samples"`
+	From    time.Time `json:"from"`
+	Until   time.Time `json:"until"`
+	Latest  float64   `json:"latest"`
+	Min     float64   `json:"min"`
+	Max     float64   `json:"max"`
+	Mean    float64   `json:"mean"`
+	StdDev  float64   `json:"std_dev"`
+	P50     float64   `json:"p50"`
+	P90     float64   `json:"p90"`
+	P95     float64   `json:"p95"`
+	P99     float64   `json:"p99"`
+
+	prices []float64
+}
+
+// newSpotPriceStats summarizes chronologically ordered samples
+func newSpotPriceStats(metro, plan string, samples []SpotPriceSample) *SpotPriceStats {
+	st := &SpotPriceStats{Metro: metro, Plan: plan, Samples: len(samples)}
+	if len(samples) == 0 {
+		return st
+	}
+	st.From, st.Until = samples[0].Time, samples[len(samples)-1].Time
+	st.Latest = samples[len(samples)-1].Price
+
+	st.prices = make([]float64, len(samples))
+	sum := 0.0
+	for i, s := range samples {
+		st.prices[i] = s.Price
+		sum += s.Price
+	}
+	st.Mean = sum / float64(len(samples))
+	for _, p := range st.prices {
+		st.StdDev += (p - st.Mean) * (p - st.Mean)
+	}
+	st.StdDev = math.Sqrt(st.StdDev / float64(len(samples)))
+
+	sort.Float64s(st.prices)
+	st.Min, st.Max = st.prices[0], st.prices[len(st.prices)-1]
+	st.P50, st.P90, st.P95, st.P99 = st.Percentile(0.5), st.Percentile(0.9), st.Percentile(0.95), st.Percentile(0.99)
+	return st
+}
+
+// Percentile returns the nearest-rank percentile of the sampled prices, with
+// q between 0 and 1
+func (st *SpotPriceStats) Percentile(q float64) float64 {
+	if len(st.prices) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q*float64(len(st.prices)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(st.prices) {
+		rank = len(st.prices) - 1
+	}
+	return st.prices[rank]
+}
+
+// ExceedFraction returns the fraction of samples priced above bid, an
+// estimate of the time an instance bidding bid would have been reclaimed
+func (st *SpotPriceStats) ExceedFraction(bid float64) float64 {
+	if len(st.prices) == 0 {
+		return 0
+	}
+	above := len(st.prices) - sort.Search(len(st.prices), func(i int) bool { return st.prices[i] > bid })
+	return float64(above) / float64(len(st.prices))
+}
+
+// SpotBidOpts configures SpotBidAdvisor.Recommend
+type SpotBidOpts struct {
+	// Metros limits the candidates. All sampled metros are considered when
+	// empty.
+	Metros []string
+
+	// Window is how far back samples are considered. All samples are used
+	// when zero.
+	Window time.Duration
+
+	// InterruptionTolerance is the acceptable fraction of time the spot price
+	// may exceed the bid, zero to bid the highest sampled price. Defaults to
+	// SpotBidInterruptionTolerance when nil.
+	InterruptionTolerance *float64
+
+	// MinSamples skips metros with fewer samples
+	MinSamples int
+}
+
+// SpotBidRecommendation is a suggested MaxBidPrice for a plan in a metro
+type SpotBidRecommendation struct {
+	Metro string  `json:"metro"`
+	Plan  string  `json:"plan"`
+	Bid   float64 `json:"bid"`
+
+	// ExpectedInterruption is the fraction of samples priced above Bid
+	ExpectedInterruption float64 `json:"expected_interruption"`
+
+	// OnDemandPrice is the hourly on-demand price of the plan, if known
+	OnDemandPrice float64 `json:"on_demand_price,omitempty"`
+
+	// Savings is the fraction saved by paying the mean spot price instead of
+	// OnDemandPrice
+	Savings float64 `json:"savings,omitempty"`
+
+	// ExceedsOnDemand is true when Bid is not below OnDemandPrice, in which
+	// case an on-demand device is the better choice
+	ExceedsOnDemand bool `json:"exceeds_on_demand,omitempty"`
+
+	Stats *SpotPriceStats `json:"stats"`
+}
+
+// SpotBidAdvisor samples spot market prices into a SpotPriceStore and
+// recommends bids from the collected time series
+//
+//	advisor := NewSpotBidAdvisor(c.SpotMarket, NewFileSpotPriceStore("prices.jsonl"))
+//	go advisor.Run(ctx, 0)
+//	...
+//	tolerance := 0.01
+//	recs, err := advisor.Recommend(plan, &SpotBidOpts{Window: 7 * 24 * time.Hour, InterruptionTolerance: &tolerance})
+//	cr.Metro, cr.MaxBidPrice = recs[0].Metro, recs[0].Bid
+type SpotBidAdvisor struct {
+	// OnError is called by Run with every sampling error. When nil, errors
+	// are logged.
+	OnError func(error)
+
+	market SpotMarketService
+	store  SpotPriceStore
+	now    func() time.Time
+}
+
+// NewSpotBidAdvisor samples market into store
+func NewSpotBidAdvisor(market SpotMarketService, store SpotPriceStore) *SpotBidAdvisor {
+	return &SpotBidAdvisor{market: market, store: store, now: time.Now}
+}
+
+// Sample records the current prices of every metro and plan
+func (a *SpotBidAdvisor) Sample() ([]SpotPriceSample, error) {
+	prices, _, err := a.market.PricesByMetro()
+	if err != nil {
+		return nil, err
+	}
+	now := a.now()
+	var samples []SpotPriceSample
+	for metro, plans := range prices {
+		for plan, price := range plans {
+			samples = append(samples, SpotPriceSample{Time: now, Metro: metro, Plan: plan, Price: price})
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Metro != samples[j].Metro {
+			return samples[i].Metro < samples[j].Metro
+		}
+		return samples[i].Plan < samples[j].Plan
+	})
+	return samples, a.store.Append(samples)
+}
+
+// Run samples prices every interval, defaulting to SpotPriceSampleInterval,
+// until ctx is done and returns ctx.Err(). Sampling errors are passed to
+// OnError and do not stop sampling.
+func (a *SpotBidAdvisor) Run(ctx context.Context, interval time.Duration) error {
+	if interval == 0 {
+		interval = SpotPriceSampleInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := a.Sample(); err != nil {
+			if a.OnError != nil {
+				a.OnError(err)
+			} else {
+				log.Printf("WARNING: spot price sampling failed: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stats summarizes the samples of plan in metro taken within window, or all
+// samples when window is zero
+func (a *SpotBidAdvisor) Stats(metro, plan string, window time.Duration) (*SpotPriceStats, error) {
+	samples, err := a.store.Samples(metro, plan, a.since(window))
+	if err != nil {
+		return nil, err
+	}
+	return newSpotPriceStats(metro, plan, samples), nil
+}
+
+func (a *SpotBidAdvisor) since(window time.Duration) time.Time {
+	if window == 0 {
+		return time.Time{}
+	}
+	return a.now().Add(-window)
+}
+
+// Recommend suggests a bid for plan in each candidate metro. The bid is the
+// lowest sampled price, rounded up to the cent, that was exceeded no more
+// than the interruption tolerance allows. Recommendations are ordered by
+// bid, with those exceeding the on-demand Plan.Pricing.Hour last.
+func (a *SpotBidAdvisor) Recommend(plan Plan, opts *SpotBidOpts) ([]SpotBidRecommendation, error) {
+	if opts == nil {
+		opts = &SpotBidOpts{}
+	}
+	tolerance := SpotBidInterruptionTolerance
+	if opts.InterruptionTolerance != nil {
+		tolerance = *opts.InterruptionTolerance
+	}
+	if tolerance < 0 || tolerance >= 1 {
+		return nil, fmt.Errorf("interruption tolerance must be between 0 and 1, got %v", tolerance)
+	}
+
+	samples, err := a.store.Samples("", plan.Slug, a.since(opts.Window))
+	if err != nil {
+		return nil, err
+	}
+	byMetro := map[string][]SpotPriceSample{}
+	for _, s := range samples {
+		if len(opts.Metros) == 0 || contains(opts.Metros, s.Metro) {
+			byMetro[s.Metro] = append(byMetro[s.Metro], s)
+		}
+	}
+
+	var onDemand float64
+	if plan.Pricing != nil {
+		onDemand = float64(plan.Pricing.Hour)
+	}
+
+	var recs []SpotBidRecommendation
+	for metro, metroSamples := range byMetro {
+		if len(metroSamples) == 0 || len(metroSamples) < opts.MinSamples {
+			continue
+		}
+		st := newSpotPriceStats(metro, plan.Slug, metroSamples)
+		// the epsilon keeps prices such as 0.07 from rounding up to 0.08
+		bid := math.Ceil(st.Percentile(1-tolerance)*100-1e-9) / 100
+		rec := SpotBidRecommendation{
+			Metro:                metro,
+			Plan:                 plan.Slug,
+			Bid:                  bid,
+			ExpectedInterruption: st.ExceedFraction(bid),
+			OnDemandPrice:        onDemand,
+			Stats:                st,
+		}
+		if onDemand > 0 {
+			rec.Savings = 1 - st.Mean/onDemand
+			rec.ExceedsOnDemand = bid >= onDemand
+		}
+		recs = append(recs, rec)
+	}
+
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].ExceedsOnDemand != recs[j].ExceedsOnDemand {
+			return !recs[i].ExceedsOnDemand
+		}
+		if recs[i].Bid != recs[j].Bid {
+			return recs[i].Bid < recs[j].Bid
+		}
+		return recs[i].Metro < recs[j].Metro
+	})
+	return recs, nil
+}
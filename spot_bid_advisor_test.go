@@ -0,0 +1,188 @@
+package packngo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeSpotMarket returns the next PriceMap of prices on every call
+type fakeSpotMarket struct {
+	SpotMarketService
+	prices []PriceMap
+	calls  int
+}
+
+func (f *fakeSpotMarket) PricesByMetro() (PriceMap, *Response, error) {
+	p := f.prices[f.calls%len(f.prices)]
+	f.calls++
+	return p, nil, nil
+}
+
+// flakySpotMarket fails on the first call and calls done on the third
+type flakySpotMarket struct {
+	SpotMarketService
+	calls int
+	done  func()
+}
+
+func (f *flakySpotMarket) PricesByMetro() (PriceMap, *Response, error) {
+	f.calls++
+	switch f.calls {
+	case 1:
+		return nil, nil, errBoom
+	case 3:
+		f.done()
+	}
+	return PriceMap{"sv": {"c3.small.x86": 0.30}}, nil, nil
+}
+
+func sampleSpotPrices(t *testing.T, store SpotPriceStore, prices []PriceMap) *SpotBidAdvisor {
+	t.Helper()
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	a := NewSpotBidAdvisor(&fakeSpotMarket{prices: prices}, store)
+	a.now = func() time.Time { return now }
+	for i := range prices {
+		now = start.Add(time.Duration(i) * time.Hour)
+		if _, err := a.Sample(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+// spotPriceSeries has sv cheap but spiky and da steady
+func spotPriceSeries() []PriceMap {
+	var prices []PriceMap
+	for i := 0; i < 20; i++ {
+		sv := 0.30
+		if i%10 == 9 {
+			sv = 2.0
+		}
+		prices = append(prices, PriceMap{
+			"sv": {"c3.small.x86": sv},
+			"da": {"c3.small.x86": 0.45},
+		})
+	}
+	return prices
+}
+
+func TestSpotBidAdvisor_Stats(t *testing.T) {
+	a := sampleSpotPrices(t, NewMemorySpotPriceStore(), spotPriceSeries())
+
+	st, err := a.Stats("sv", "c3.small.x86", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Samples != 20 || st.Min != 0.30 || st.Max != 2.0 || st.P50 != 0.30 || st.P95 != 2.0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	if st.Latest != 2.0 || !st.Until.Equal(time.Date(2021, 1, 1, 19, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected latest sample %v at %s", st.Latest, st.Until)
+	}
+	if got := st.ExceedFraction(0.30); got != 0.1 {
+		t.Errorf("ExceedFraction(0.30) = %v, want 0.1", got)
+	}
+
+	st, err = a.Stats("sv", "c3.small.x86", 5*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Samples != 6 {
+		t.Errorf("expected 6 samples within window, got %d", st.Samples)
+	}
+}
+
+func TestSpotBidAdvisor_Recommend(t *testing.T) {
+	a := sampleSpotPrices(t, NewMemorySpotPriceStore(), spotPriceSeries())
+	plan := Plan{Slug: "c3.small.x86", Pricing: &Pricing{Hour: 1.5}}
+	tolerance := func(f float64) *float64 { return &f }
+
+	// a 15% tolerance accepts the spikes in sv
+	recs, err := a.Recommend(plan, &SpotBidOpts{InterruptionTolerance: tolerance(0.15)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].Metro != "sv" || recs[0].Bid != 0.30 || recs[0].ExpectedInterruption != 0.1 {
+		t.Fatalf("unexpected recommendations %+v", recs)
+	}
+	if recs[0].ExceedsOnDemand || recs[0].Savings <= 0 {
+		t.Errorf("unexpected on-demand comparison %+v", recs[0])
+	}
+
+	// a 5% tolerance requires outbidding the sv spikes, above on-demand
+	recs, err = a.Recommend(plan, &SpotBidOpts{InterruptionTolerance: tolerance(0.05)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recs[0].Metro != "da" || recs[0].Bid != 0.45 || recs[0].ExpectedInterruption != 0 {
+		t.Errorf("unexpected best recommendation %+v", recs[0])
+	}
+	if recs[1].Metro != "sv" || !recs[1].ExceedsOnDemand {
+		t.Errorf("expected sv to exceed on-demand, got %+v", recs[1])
+	}
+
+	// no tolerance bids the highest sampled price
+	recs, err = a.Recommend(plan, &SpotBidOpts{Metros: []string{"sv"}, InterruptionTolerance: tolerance(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].Bid != 2.0 || recs[0].ExpectedInterruption != 0 {
+		t.Errorf("unexpected zero tolerance recommendation %+v", recs)
+	}
+
+	recs, err = a.Recommend(plan, &SpotBidOpts{Metros: []string{"sv"}, MinSamples: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 0 {
+		t.Errorf("expected no recommendation with too few samples, got %+v", recs)
+	}
+
+	if _, err := a.Recommend(plan, &SpotBidOpts{InterruptionTolerance: tolerance(1)}); err == nil {
+		t.Error("expected invalid tolerance error")
+	}
+}
+
+func TestSpotBidAdvisor_RunContinuesAfterError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewMemorySpotPriceStore()
+	a := NewSpotBidAdvisor(&flakySpotMarket{done: cancel}, store)
+	var errs []error
+	a.OnError = func(err error) { errs = append(errs, err) }
+
+	if err := a.Run(ctx, time.Millisecond); err != context.Canceled {
+		t.Errorf("Run() = %v, want context.Canceled", err)
+	}
+	if len(errs) != 1 || errs[0] != errBoom {
+		t.Errorf("OnError errors = %v, want [errBoom]", errs)
+	}
+	samples, err := store.Samples("sv", "c3.small.x86", time.Time{})
+	if err != nil || len(samples) < 2 {
+		t.Errorf("expected samples after the error, got %v, %v", samples, err)
+	}
+}
+
+func TestFileSpotPriceStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.jsonl")
+	store := NewFileSpotPriceStore(path)
+
+	samples, err := store.Samples("", "", time.Time{})
+	if err != nil || len(samples) != 0 {
+		t.Fatalf("expected empty store, got %v, %v", samples, err)
+	}
+
+	sampleSpotPrices(t, store, spotPriceSeries()[:3])
+
+	reopened := NewFileSpotPriceStore(path)
+	samples, err = reopened.Samples("da", "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 || samples[2].Price != 0.45 || !samples[2].Time.Equal(time.Date(2021, 1, 1, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected samples %+v", samples)
+	}
+}
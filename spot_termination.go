@@ -0,0 +1,257 @@
+package packngo
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/packethost/packngo/metadata"
+)
+
+const (
+	// SpotTerminationWatchInterval is the default interval between polls of a
+	// SpotTerminationWatcher
+	SpotTerminationWatchInterval = 30 * time.Second
+)
+
+// SpotTerminationEventType identifies the kind of SpotTerminationEvent
+type SpotTerminationEventType string
+
+// SpotTerminationEventType enums
+const (
+	// SpotTerminationScheduled is emitted when a termination time appears or
+	// changes
+	SpotTerminationScheduled SpotTerminationEventType = "scheduled"
+
+	// SpotTerminationApproaching is emitted once for each threshold crossed
+	SpotTerminationApproaching SpotTerminationEventType = "approaching"
+
+	// SpotTerminationGone is emitted when a watched device is no longer listed
+	SpotTerminationGone SpotTerminationEventType = "gone"
+)
+
+// SpotTermination is the termination state of a spot instance
+type SpotTermination struct {
+	DeviceID string
+	Hostname string
+
+	// TerminationTime is nil until the instance is scheduled for reclamation
+	TerminationTime *time.Time
+}
+
+// SpotTerminationEvent is passed to SpotTerminationWatcher handlers
+type SpotTerminationEvent struct {
+	Type            SpotTerminationEventType
+	DeviceID        string
+	Hostname        string
+	TerminationTime time.Time
+
+	// Remaining is the time left until TerminationTime when the event was emitted
+	Remaining time.Duration
+
+	// Threshold is the crossed threshold of SpotTerminationApproaching events
+	Threshold time.Duration
+}
+
+// SpotTerminationSource lists the spot instances to watch
+type SpotTerminationSource interface {
+	SpotTerminations() ([]SpotTermination, error)
+}
+
+// SpotTerminationSourceFunc adapts a function to the SpotTerminationSource
+// interface
+type SpotTerminationSourceFunc func() ([]SpotTermination, error)
+
+// SpotTerminations calls f()
+func (f SpotTerminationSourceFunc) SpotTerminations() ([]SpotTermination, error) {
+	return f()
+}
+
+// ProjectSpotTerminations lists the spot instances of a project through the API
+func ProjectSpotTerminations(s DeviceService, projectID string) SpotTerminationSource {
+	return SpotTerminationSourceFunc(func() ([]SpotTermination, error) {
+		devices, _, err := s.List(projectID, nil)
+		if err != nil {
+			return nil, err
+		}
+		var terms []SpotTermination
+		for _, d := range devices {
+			if !d.SpotInstance {
+				continue
+			}
+			t := SpotTermination{DeviceID: d.ID, Hostname: d.Hostname}
+			if d.TerminationTime != nil && !d.TerminationTime.IsZero() {
+				tt := d.TerminationTime.Time
+				t.TerminationTime = &tt
+			}
+			terms = append(terms, t)
+		}
+		return terms, nil
+	})
+}
+
+// MetadataSpotTermination reads the termination time of the current device
+// from the metadata service at baseURL, typically metadata.BaseURL. It is
+// intended to run on the spot instance itself.
+func MetadataSpotTermination(baseURL string) SpotTerminationSource {
+	return SpotTerminationSourceFunc(func() ([]SpotTermination, error) {
+		md, err := metadata.GetMetadataFromURL(baseURL)
+		if err != nil {
+			return nil, err
+		}
+		t := SpotTermination{DeviceID: md.ID, Hostname: md.Hostname}
+		if md.Spot != nil {
+			t.TerminationTime = md.Spot.TerminationTime
+		}
+		return []SpotTermination{t}, nil
+	})
+}
+
+type spotWatchState struct {
+	hostname        string
+	terminationTime *time.Time
+	crossed         map[time.Duration]bool
+}
+
+// SpotTerminationWatcher polls a SpotTerminationSource and calls its handlers
+// when a termination time is scheduled, when the time left crosses one of the
+// thresholds, and when a device disappears.
+//
+//	w := NewSpotTerminationWatcher(ProjectSpotTerminations(c.Devices, projectID), 10*time.Minute, time.Minute)
+//	w.Handle(func(e SpotTerminationEvent) {
+//		if e.Type == SpotTerminationApproaching {
+//			drain(e.DeviceID)
+//		}
+//	})
+//	err := w.Run(ctx)
+type SpotTerminationWatcher struct {
+	// Interval between polls. Defaults to SpotTerminationWatchInterval.
+	Interval time.Duration
+
+	// OnError is called by Run with every error of the source. When nil,
+	// errors are logged.
+	OnError func(error)
+
+	source     SpotTerminationSource
+	thresholds []time.Duration
+	handlers   []func(SpotTerminationEvent)
+	now        func() time.Time
+
+	mu    sync.Mutex
+	state map[string]*spotWatchState
+}
+
+// NewSpotTerminationWatcher watches source, emitting SpotTerminationApproaching
+// events when the time left before termination falls below each threshold
+func NewSpotTerminationWatcher(source SpotTerminationSource, thresholds ...time.Duration) *SpotTerminationWatcher {
+	sorted := append([]time.Duration{}, thresholds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+	return &SpotTerminationWatcher{
+		source:     source,
+		thresholds: sorted,
+		now:        time.Now,
+		state:      map[string]*spotWatchState{},
+	}
+}
+
+// Handle registers a function called for every event. Handlers are called
+// sequentially from the polling goroutine.
+func (w *SpotTerminationWatcher) Handle(fn func(SpotTerminationEvent)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers = append(w.handlers, fn)
+}
+
+// Run polls until ctx is done and returns ctx.Err(). Errors from the source
+// are passed to OnError and do not stop the watcher, so that a transient
+// failure does not hide a later termination.
+func (w *SpotTerminationWatcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval == 0 {
+		interval = SpotTerminationWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Poll(); err != nil {
+			if w.OnError != nil {
+				w.OnError(err)
+			} else {
+				log.Printf("WARNING: spot termination poll failed: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll checks the source once, calls the handlers and returns the emitted
+// events
+func (w *SpotTerminationWatcher) Poll() ([]SpotTerminationEvent, error) {
+	terms, err := w.source.SpotTerminations()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	now := w.now()
+	var events []SpotTerminationEvent
+	seen := map[string]bool{}
+	for _, t := range terms {
+		seen[t.DeviceID] = true
+		st, ok := w.state[t.DeviceID]
+		if !ok {
+			st = &spotWatchState{crossed: map[time.Duration]bool{}}
+			w.state[t.DeviceID] = st
+		}
+		st.hostname = t.Hostname
+		if t.TerminationTime == nil {
+			continue
+		}
+
+		tt := *t.TerminationTime
+		remaining := tt.Sub(now)
+		event := SpotTerminationEvent{DeviceID: t.DeviceID, Hostname: t.Hostname, TerminationTime: tt, Remaining: remaining}
+
+		if st.terminationTime == nil || !st.terminationTime.Equal(tt) {
+			st.terminationTime = &tt
+			st.crossed = map[time.Duration]bool{}
+			event.Type = SpotTerminationScheduled
+			events = append(events, event)
+		}
+		for _, threshold := range w.thresholds {
+			if remaining <= threshold && !st.crossed[threshold] {
+				st.crossed[threshold] = true
+				event.Type, event.Threshold = SpotTerminationApproaching, threshold
+				events = append(events, event)
+			}
+		}
+	}
+
+	for id, st := range w.state {
+		if seen[id] {
+			continue
+		}
+		event := SpotTerminationEvent{Type: SpotTerminationGone, DeviceID: id, Hostname: st.hostname}
+		if st.terminationTime != nil {
+			event.TerminationTime = *st.terminationTime
+			event.Remaining = st.terminationTime.Sub(now)
+		}
+		events = append(events, event)
+		delete(w.state, id)
+	}
+	handlers := append([]func(SpotTerminationEvent){}, w.handlers...)
+	w.mu.Unlock()
+
+	for _, e := range events {
+		for _, fn := range handlers {
+			fn(e)
+		}
+	}
+	return events, nil
+}
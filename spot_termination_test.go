@@ -0,0 +1,181 @@
+package packngo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeSpotDevices struct {
+	DeviceService
+	devices []Device
+}
+
+func (f *fakeSpotDevices) List(projectID string, opts *ListOptions) ([]Device, *Response, error) {
+	return f.devices, nil, nil
+}
+
+func TestProjectSpotTerminations(t *testing.T) {
+	tt := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := &fakeSpotDevices{devices: []Device{
+		{ID: "on-demand"},
+		{ID: "spot-a", Hostname: "a", SpotInstance: true},
+		{ID: "spot-b", Hostname: "b", SpotInstance: true, TerminationTime: &Timestamp{tt}},
+	}}
+
+	terms, err := ProjectSpotTerminations(s, "project").SpotTerminations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(terms) != 2 {
+		t.Fatalf("expected 2 spot instances, got %d", len(terms))
+	}
+	if terms[0].DeviceID != "spot-a" || terms[0].TerminationTime != nil {
+		t.Errorf("unexpected termination %+v", terms[0])
+	}
+	if terms[1].DeviceID != "spot-b" || !terms[1].TerminationTime.Equal(tt) {
+		t.Errorf("unexpected termination %+v", terms[1])
+	}
+}
+
+func TestMetadataSpotTermination(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": "dev", "hostname": "spot", "spot": {"termination_time": "2021-01-01T12:00:00Z"}}`)
+	}))
+	defer ts.Close()
+
+	terms, err := MetadataSpotTermination(ts.URL).SpotTerminations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(terms) != 1 || terms[0].DeviceID != "dev" || terms[0].TerminationTime == nil {
+		t.Fatalf("unexpected terminations %+v", terms)
+	}
+	if !terms[0].TerminationTime.Equal(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected termination time %s", terms[0].TerminationTime)
+	}
+}
+
+func TestSpotTerminationWatcher_Poll(t *testing.T) {
+	now := time.Date(2021, 1, 1, 11, 0, 0, 0, time.UTC)
+	tt := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	terms := []SpotTermination{{DeviceID: "a"}, {DeviceID: "b"}}
+	w := NewSpotTerminationWatcher(SpotTerminationSourceFunc(func() ([]SpotTermination, error) {
+		return terms, nil
+	}), time.Minute, 30*time.Minute)
+	w.now = func() time.Time { return now }
+
+	var handled []SpotTerminationEvent
+	w.Handle(func(e SpotTerminationEvent) { handled = append(handled, e) })
+
+	poll := func() []SpotTerminationEvent {
+		t.Helper()
+		events, err := w.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return events
+	}
+
+	if events := poll(); len(events) != 0 {
+		t.Fatalf("expected no events before scheduling, got %+v", events)
+	}
+
+	terms[0].TerminationTime = &tt
+	events := poll()
+	if len(events) != 1 || events[0].Type != SpotTerminationScheduled || events[0].DeviceID != "a" || events[0].Remaining != time.Hour {
+		t.Fatalf("expected scheduled event, got %+v", events)
+	}
+
+	if events := poll(); len(events) != 0 {
+		t.Fatalf("expected no repeated events, got %+v", events)
+	}
+
+	now = tt.Add(-20 * time.Minute)
+	events = poll()
+	if len(events) != 1 || events[0].Type != SpotTerminationApproaching || events[0].Threshold != 30*time.Minute {
+		t.Fatalf("expected 30m approaching event, got %+v", events)
+	}
+
+	now = tt.Add(-30 * time.Second)
+	terms = terms[:1]
+	events = poll()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	}
+	if events[0].Type != SpotTerminationApproaching || events[0].Threshold != time.Minute {
+		t.Errorf("expected 1m approaching event, got %+v", events[0])
+	}
+	if events[1].Type != SpotTerminationGone || events[1].DeviceID != "b" {
+		t.Errorf("expected gone event for b, got %+v", events[1])
+	}
+
+	if len(handled) != 4 {
+		t.Errorf("expected handler to see 4 events, got %d", len(handled))
+	}
+}
+
+func TestSpotTerminationWatcher_PollRescheduled(t *testing.T) {
+	now := time.Date(2021, 1, 1, 11, 0, 0, 0, time.UTC)
+	tt := now.Add(2 * time.Minute)
+	terms := []SpotTermination{{DeviceID: "a", TerminationTime: &tt}}
+	w := NewSpotTerminationWatcher(SpotTerminationSourceFunc(func() ([]SpotTermination, error) {
+		return terms, nil
+	}), 5*time.Minute)
+	w.now = func() time.Time { return now }
+
+	events, _ := w.Poll()
+	if len(events) != 2 || events[0].Type != SpotTerminationScheduled || events[1].Type != SpotTerminationApproaching {
+		t.Fatalf("expected scheduled and approaching events, got %+v", events)
+	}
+
+	later := now.Add(time.Hour)
+	terms[0].TerminationTime = &later
+	events, _ = w.Poll()
+	if len(events) != 1 || events[0].Type != SpotTerminationScheduled || !events[0].TerminationTime.Equal(later) {
+		t.Fatalf("expected rescheduled event, got %+v", events)
+	}
+}
+
+func TestSpotTerminationWatcher_PollError(t *testing.T) {
+	w := NewSpotTerminationWatcher(SpotTerminationSourceFunc(func() ([]SpotTermination, error) {
+		return nil, errBoom
+	}))
+	if _, err := w.Poll(); err != errBoom {
+		t.Errorf("expected errBoom, got %v", err)
+	}
+}
+
+func TestSpotTerminationWatcher_RunContinuesAfterError(t *testing.T) {
+	tt := time.Now().Add(time.Hour)
+	polls := 0
+	w := NewSpotTerminationWatcher(SpotTerminationSourceFunc(func() ([]SpotTermination, error) {
+		polls++
+		if polls == 1 {
+			return nil, errBoom
+		}
+		return []SpotTermination{{DeviceID: "dev-1", TerminationTime: &tt}}, nil
+	}))
+	w.Interval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var errs []error
+	w.OnError = func(err error) { errs = append(errs, err) }
+	w.Handle(func(e SpotTerminationEvent) {
+		if e.Type == SpotTerminationScheduled {
+			cancel()
+		}
+	})
+
+	if err := w.Run(ctx); err != context.Canceled {
+		t.Errorf("Run() = %v, want context.Canceled", err)
+	}
+	if len(errs) != 1 || errs[0] != errBoom {
+		t.Errorf("OnError errors = %v, want [errBoom]", errs)
+	}
+}
@@ -1,11 +1,24 @@
 package packngo
 
 import (
+	"fmt"
 	"math"
 	"path"
+	"time"
 )
 
-const spotMarketRequestBasePath = "/spot-market-requests"
+const (
+	spotMarketRequestBasePath = "/spot-market-requests"
+
+	// SpotMarketRequestFulfillmentTimeout is the default time
+	// WaitSpotMarketRequestFulfilled waits for a request to reach DevicesMin
+	// active devices
+	SpotMarketRequestFulfillmentTimeout = 30 * time.Minute
+
+	// SpotMarketRequestFulfillmentCheck is the default interval between
+	// fulfillment checks
+	SpotMarketRequestFulfillmentCheck = 15 * time.Second
+)
 
 type SpotMarketRequestService interface {
 	List(string, *ListOptions) ([]SpotMarketRequest, *Response, error)
@@ -56,9 +69,11 @@ type SpotMarketRequestInstanceParameters struct {
 }
 
 type SpotMarketRequestServiceOp struct {
-	client *Client
+	client requestDoer
 }
 
+var _ SpotMarketRequestService = (*SpotMarketRequestServiceOp)(nil)
+
 func roundPlus(f float64, places int) float64 {
 	shift := math.Pow(10, float64(places))
 	return math.Floor(f*shift+.5) / shift
@@ -130,3 +145,228 @@ func (s *SpotMarketRequestServiceOp) Delete(id string, forceDelete bool) (*Respo
 	}
 	return s.client.DoRequest("DELETE", apiPath, params, nil)
 }
+
+// spotMarketRequestIncludes are the relations needed to track and replace a
+// request
+var spotMarketRequestIncludes = []string{"devices", "facilities", "metro", "project", "plan"}
+
+// SpotMarketRequestProgress is the fulfillment state of a SpotMarketRequest
+type SpotMarketRequestProgress struct {
+	RequestID  string
+	DevicesMin int
+	DevicesMax int
+
+	// Active lists the devices in the active state
+	Active []Device
+
+	// Pending lists the devices that are still provisioning
+	Pending []Device
+
+	// Lost lists the devices that were part of the request at an earlier
+	// check but are no longer listed, typically because they were reclaimed
+	Lost []Device
+
+	// Fulfilled is true when at least DevicesMin devices are active
+	Fulfilled bool
+}
+
+func (p SpotMarketRequestProgress) String() string {
+	return fmt.Sprintf("spot market request %s: %d/%d active (max %d), %d pending, %d lost",
+		p.RequestID, len(p.Active), p.DevicesMin, p.DevicesMax, len(p.Pending), len(p.Lost))
+}
+
+// SpotMarketRequestTracker computes the progress of a request across
+// successive checks, remembering the devices seen so far in order to detect
+// devices lost to reclamation
+type SpotMarketRequestTracker struct {
+	seen map[string]Device
+}
+
+// NewSpotMarketRequestTracker returns a tracker with no devices seen
+func NewSpotMarketRequestTracker() *SpotMarketRequestTracker {
+	return &SpotMarketRequestTracker{seen: map[string]Device{}}
+}
+
+// Update records the devices of smr and returns its progress. Devices lost
+// since the previous Update are reported once.
+func (t *SpotMarketRequestTracker) Update(smr *SpotMarketRequest) *SpotMarketRequestProgress {
+	p := &SpotMarketRequestProgress{
+		RequestID:  smr.ID,
+		DevicesMin: smr.DevicesMin,
+		DevicesMax: smr.DevicesMax,
+	}
+
+	current := map[string]bool{}
+	for _, d := range smr.Devices {
+		switch d.State {
+		case "active":
+			p.Active = append(p.Active, d)
+		case "deleted", "failed":
+			continue
+		default:
+			p.Pending = append(p.Pending, d)
+		}
+		current[d.ID] = true
+		t.seen[d.ID] = d
+	}
+	for id, d := range t.seen {
+		if !current[id] {
+			p.Lost = append(p.Lost, d)
+			delete(t.seen, id)
+		}
+	}
+
+	p.Fulfilled = len(p.Active) >= smr.DevicesMin
+	return p
+}
+
+// SpotMarketRequestWaitOpts configures WaitSpotMarketRequestFulfilled
+type SpotMarketRequestWaitOpts struct {
+	// Timeout defaults to SpotMarketRequestFulfillmentTimeout
+	Timeout time.Duration
+
+	// PollInterval defaults to SpotMarketRequestFulfillmentCheck
+	PollInterval time.Duration
+
+	// Progress is called after every check
+	Progress func(*SpotMarketRequestProgress)
+}
+
+// SpotMarketRequestFulfillmentError is returned by
+// WaitSpotMarketRequestFulfilled when a request did not reach DevicesMin
+// active devices in time
+type SpotMarketRequestFulfillmentError struct {
+	Progress *SpotMarketRequestProgress
+}
+
+func (e *SpotMarketRequestFulfillmentError) Error() string {
+	return fmt.Sprintf("timed out waiting for fulfillment of %s", e.Progress)
+}
+
+// WaitSpotMarketRequestFulfilled polls a spot market request until at least
+// DevicesMin of its devices are active. The returned progress accumulates the
+// devices lost during the wait. On timeout a
+// *SpotMarketRequestFulfillmentError is returned.
+func WaitSpotMarketRequestFulfilled(s SpotMarketRequestService, id string, opts *SpotMarketRequestWaitOpts) (*SpotMarketRequest, *SpotMarketRequestProgress, error) {
+	if opts == nil {
+		opts = &SpotMarketRequestWaitOpts{}
+	}
+	timeout, interval := opts.Timeout, opts.PollInterval
+	if timeout == 0 {
+		timeout = SpotMarketRequestFulfillmentTimeout
+	}
+	if interval == 0 {
+		interval = SpotMarketRequestFulfillmentCheck
+	}
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	tracker := NewSpotMarketRequestTracker()
+	var lost []Device
+	getOpts := (&GetOptions{}).Including(spotMarketRequestIncludes...)
+	for {
+		smr, _, err := s.Get(id, getOpts)
+		if err != nil {
+			return nil, nil, err
+		}
+		progress := tracker.Update(smr)
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+		lost = append(lost, progress.Lost...)
+
+		if progress.Fulfilled {
+			progress.Lost = lost
+			return smr, progress, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			progress.Lost = lost
+			return smr, progress, &SpotMarketRequestFulfillmentError{Progress: progress}
+		}
+	}
+}
+
+// SpotMarketRequestResizeRequest sets the new size of a spot market request
+type SpotMarketRequestResizeRequest struct {
+	DevicesMin int
+	DevicesMax int
+}
+
+// SpotMarketRequestResizeResult is the outcome of ResizeSpotMarketRequest
+type SpotMarketRequestResizeResult struct {
+	// Request replaces the resized request. It is nil when the retained
+	// devices already reach DevicesMax.
+	Request *SpotMarketRequest
+
+	// Retained lists the devices of the replaced request that kept running.
+	// They no longer belong to any spot market request.
+	Retained []Device
+}
+
+// ResizeSpotMarketRequest changes the DevicesMin and DevicesMax of a spot
+// market request. The API cannot update requests, so the original request is
+// deleted without terminating its devices, and then a replacement request is
+// created with the same bid, location and instance parameters. The
+// replacement is sized to cover only the difference between the retained
+// devices and the new bounds. Deleting first means the two requests are never
+// fulfilled at the same time.
+//
+// Shrinking below the number of running devices is refused; delete devices
+// first. If deleting the original fails, nothing is changed. If creating the
+// replacement fails, the result lists the retained devices and the error
+// reports that the original request is gone.
+func ResizeSpotMarketRequest(s SpotMarketRequestService, id string, request *SpotMarketRequestResizeRequest) (*SpotMarketRequestResizeResult, *Response, error) {
+	if request == nil || request.DevicesMin < 0 || request.DevicesMax < 1 || request.DevicesMin > request.DevicesMax {
+		return nil, nil, fmt.Errorf("resize requires 0 <= DevicesMin <= DevicesMax and DevicesMax >= 1")
+	}
+
+	smr, resp, err := s.Get(id, (&GetOptions{}).Including(spotMarketRequestIncludes...))
+	if err != nil {
+		return nil, resp, err
+	}
+
+	progress := NewSpotMarketRequestTracker().Update(smr)
+	result := &SpotMarketRequestResizeResult{Retained: append(progress.Active, progress.Pending...)}
+	running := len(result.Retained)
+	if request.DevicesMax < running {
+		return nil, resp, fmt.Errorf("spot market request %s has %d running devices, more than the requested maximum of %d", id, running, request.DevicesMax)
+	}
+
+	resp, err = s.Delete(id, false)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	if request.DevicesMax > running {
+		cr := smr.SpotMarketRequestCreateRequest
+		cr.DevicesMin = request.DevicesMin - running
+		if cr.DevicesMin < 0 {
+			cr.DevicesMin = 0
+		}
+		cr.DevicesMax = request.DevicesMax - running
+		// requests placed in a metro also list its facilities, but the API
+		// refuses both
+		cr.FacilityIDs, cr.Metro = nil, ""
+		if smr.Metro != nil && smr.Metro.Code != "" {
+			cr.Metro = smr.Metro.Code
+		} else {
+			for _, f := range smr.Facilities {
+				cr.FacilityIDs = append(cr.FacilityIDs, f.ID)
+			}
+		}
+		if cr.Parameters.Plan == "" {
+			cr.Parameters.Plan = smr.Plan.Slug
+		}
+
+		result.Request, resp, err = s.Create(&cr, smr.Project.ID)
+		if err != nil {
+			return result, resp, fmt.Errorf("spot market request %s deleted but creating its replacement failed: %w", id, err)
+		}
+	}
+	return result, resp, nil
+}
@@ -3,8 +3,11 @@ package packngo
 import (
 	"fmt"
 	"log"
+	"path"
 	"testing"
 	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func deleteSpotMarketRequest(t *testing.T, c *Client, id string, force bool) {
@@ -158,3 +161,176 @@ out2:
 		}
 	}
 }
+
+func TestWaitSpotMarketRequestFulfilled(t *testing.T) {
+	const smrID = "b3b5cd2c-5b0b-4e3b-9c9c-8bfeaf0cc9c1"
+	checks := [][]Device{
+		{{ID: "a", State: "provisioning"}, {ID: "b", State: "provisioning"}},
+		{{ID: "a", State: "active"}},
+		{{ID: "a", State: "active"}, {ID: "c", State: "active"}},
+	}
+	polls := 0
+	s := &SpotMarketRequestServiceOp{client: &MockClient{
+		fnDoRequest: func(method, pathURL string, body, v interface{}) (*Response, error) {
+			smr := *v.(**SpotMarketRequest)
+			smr.ID = smrID
+			smr.DevicesMin = 2
+			smr.DevicesMax = 3
+			smr.Devices = checks[polls]
+			polls++
+			return mockResponse(200, "", nil), nil
+		},
+	}}
+
+	var reported []*SpotMarketRequestProgress
+	smr, progress, err := WaitSpotMarketRequestFulfilled(s, smrID, &SpotMarketRequestWaitOpts{
+		PollInterval: time.Millisecond,
+		Progress:     func(p *SpotMarketRequestProgress) { reported = append(reported, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smr.ID != smrID || !progress.Fulfilled || len(progress.Active) != 2 {
+		t.Fatalf("unexpected progress %s", progress)
+	}
+	if len(progress.Lost) != 1 || progress.Lost[0].ID != "b" {
+		t.Errorf("expected device b to be lost, got %+v", progress.Lost)
+	}
+	if len(reported) != 3 || len(reported[0].Pending) != 2 || len(reported[1].Lost) != 1 {
+		t.Errorf("unexpected progress reports %v", reported)
+	}
+}
+
+func TestWaitSpotMarketRequestFulfilledTimeout(t *testing.T) {
+	const smrID = "b3b5cd2c-5b0b-4e3b-9c9c-8bfeaf0cc9c1"
+	s := &SpotMarketRequestServiceOp{client: &MockClient{
+		fnDoRequest: func(method, pathURL string, body, v interface{}) (*Response, error) {
+			smr := *v.(**SpotMarketRequest)
+			smr.ID = smrID
+			smr.DevicesMin = 1
+			return mockResponse(200, "", nil), nil
+		},
+	}}
+	_, _, err := WaitSpotMarketRequestFulfilled(s, smrID, &SpotMarketRequestWaitOpts{Timeout: 5 * time.Millisecond, PollInterval: time.Millisecond})
+	if _, ok := err.(*SpotMarketRequestFulfillmentError); !ok {
+		t.Errorf("expected *SpotMarketRequestFulfillmentError, got %v", err)
+	}
+}
+
+func TestResizeSpotMarketRequest(t *testing.T) {
+	const (
+		smrID     = "b3b5cd2c-5b0b-4e3b-9c9c-8bfeaf0cc9c1"
+		newID     = "5e0b9c0e-5d7a-4b4e-8d4e-2f2fcb4b2d11"
+		projectID = "93125c2a-8b78-4d4f-a3c4-7367d6b7cca8"
+	)
+
+	tests := []struct {
+		name           string
+		noMetro        bool
+		failCreate     bool
+		request        SpotMarketRequestResizeRequest
+		wantCreate     *SpotMarketRequestCreateRequest
+		wantDeleted    bool
+		wantErr        bool
+		wantRetained   int
+		wantNewRequest bool
+	}{
+		{
+			name:           "Grow",
+			request:        SpotMarketRequestResizeRequest{DevicesMin: 3, DevicesMax: 5},
+			wantCreate:     &SpotMarketRequestCreateRequest{DevicesMin: 1, DevicesMax: 3, MaxBidPrice: 0.5, Metro: "sv", Parameters: SpotMarketRequestInstanceParameters{Plan: "c3.small.x86"}},
+			wantDeleted:    true,
+			wantRetained:   2,
+			wantNewRequest: true,
+		},
+		{
+			name:           "GrowFacilities",
+			noMetro:        true,
+			request:        SpotMarketRequestResizeRequest{DevicesMin: 3, DevicesMax: 5},
+			wantCreate:     &SpotMarketRequestCreateRequest{DevicesMin: 1, DevicesMax: 3, MaxBidPrice: 0.5, FacilityIDs: []string{"fac-sv15"}, Parameters: SpotMarketRequestInstanceParameters{Plan: "c3.small.x86"}},
+			wantDeleted:    true,
+			wantRetained:   2,
+			wantNewRequest: true,
+		},
+		{
+			name:         "Exact",
+			request:      SpotMarketRequestResizeRequest{DevicesMin: 1, DevicesMax: 2},
+			wantDeleted:  true,
+			wantRetained: 2,
+		},
+		{
+			name:         "CreateFails",
+			failCreate:   true,
+			request:      SpotMarketRequestResizeRequest{DevicesMin: 3, DevicesMax: 5},
+			wantCreate:   &SpotMarketRequestCreateRequest{DevicesMin: 1, DevicesMax: 3, MaxBidPrice: 0.5, Metro: "sv", Parameters: SpotMarketRequestInstanceParameters{Plan: "c3.small.x86"}},
+			wantDeleted:  true,
+			wantErr:      true,
+			wantRetained: 2,
+		},
+		{
+			name:    "ShrinkBelowRunning",
+			request: SpotMarketRequestResizeRequest{DevicesMin: 1, DevicesMax: 1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *SpotMarketRequestCreateRequest
+			deleted := false
+			s := &SpotMarketRequestServiceOp{client: &MockClient{
+				fnDoRequest: func(method, pathURL string, body, v interface{}) (*Response, error) {
+					switch method {
+					case "GET":
+						smr := *v.(**SpotMarketRequest)
+						smr.ID = smrID
+						smr.DevicesMin, smr.DevicesMax = 1, 2
+						smr.MaxBidPrice = 0.5
+						// metro requests also list the facilities of the metro
+						smr.Facilities = []Facility{{ID: "fac-sv15", Code: "sv15"}}
+						if !tt.noMetro {
+							smr.Metro = &Metro{Code: "sv"}
+						}
+						smr.Project = Project{ID: projectID}
+						smr.Plan = Plan{Slug: "c3.small.x86"}
+						smr.Devices = []Device{{ID: "a", State: "active"}, {ID: "b", State: "provisioning"}, {ID: "c", State: "deleted"}}
+					case "POST":
+						if !deleted {
+							t.Error("replacement created before the original was deleted")
+						}
+						created = body.(*SpotMarketRequestCreateRequest)
+						if tt.failCreate {
+							return nil, errBoom
+						}
+						v.(*SpotMarketRequest).ID = newID
+					case "DELETE":
+						if pathURL != path.Join(spotMarketRequestBasePath, smrID) || body != (*map[string]bool)(nil) {
+							t.Errorf("unexpected delete %s %v", pathURL, body)
+						}
+						deleted = true
+					}
+					return mockResponse(200, "", nil), nil
+				},
+			}}
+
+			result, _, err := ResizeSpotMarketRequest(s, smrID, &tt.request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.wantCreate, created); diff != "" {
+				t.Errorf("Resize() create mismatch (-want +got):\n%s", diff)
+			}
+			if deleted != tt.wantDeleted {
+				t.Errorf("Resize() deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			if tt.wantErr && result == nil {
+				return
+			}
+			if len(result.Retained) != tt.wantRetained {
+				t.Errorf("Resize() retained %d devices, want %d", len(result.Retained), tt.wantRetained)
+			}
+			if (result.Request != nil) != tt.wantNewRequest || (result.Request != nil && result.Request.ID != newID) {
+				t.Errorf("Resize() request = %+v", result.Request)
+			}
+		})
+	}
+}
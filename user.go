@@ -97,6 +97,10 @@ type UserCreateRequest struct {
 	Emails       []EmailRequest `json:"emails,omitempty"`
 }
 
+func (u UserCreateRequest) String() string {
+	return Stringify(u)
+}
+
 // UserUpdateRequest struct for UserService.Update
 type UserUpdateRequest struct {
 	FirstName   *string      `json:"first_name,omitempty"`
@@ -108,6 +112,10 @@ type UserUpdateRequest struct {
 	Customdata  *interface{} `json:"customdata,omitempty"`
 }
 
+func (u UserUpdateRequest) String() string {
+	return Stringify(u)
+}
+
 func (u User) String() string {
 	return Stringify(u)
 }
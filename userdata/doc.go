@@ -0,0 +1,9 @@
+// Package userdata composes cloud-init user data for Equinix Metal devices.
+//
+// Cloud-config documents, shell scripts and include URLs are combined into a
+// single MIME multipart document that can be used as the UserData of a
+// packngo.DeviceCreateRequest or packngo.DeviceUpdateRequest.
+//
+// For more information, see
+// https://metal.equinix.com/developers/docs/servers/user-data/
+package userdata
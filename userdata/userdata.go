@@ -0,0 +1,247 @@
+package userdata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/packethost/packngo"
+	"github.com/packethost/packngo/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// Content types understood by cloud-init
+const (
+	ContentTypeCloudConfig = "text/cloud-config"
+	ContentTypeShellScript = "text/x-shellscript"
+	ContentTypeIncludeURL  = "text/x-include-url"
+	ContentTypeBoothook    = "text/cloud-boothook"
+)
+
+const (
+	cloudConfigHeader = "#cloud-config"
+
+	// Boundary separates the parts of the multipart document
+	Boundary = "PACKNGO-USERDATA-BOUNDARY"
+)
+
+// Part is a single document within the user data
+type Part struct {
+	// ContentType is one of the ContentType constants
+	ContentType string
+
+	// Filename is reported to cloud-init in the Content-Disposition header
+	Filename string
+
+	Content string
+}
+
+// Composer combines Parts into a MIME multipart user data document
+//
+//	ud, err := userdata.New().
+//		CloudConfig("packages: [nginx]").
+//		ShellScript("setup.sh", "#!/bin/sh\nsystemctl enable --now nginx").
+//		PhoneHome().
+//		Render()
+type Composer struct {
+	parts []Part
+	gzip  bool
+}
+
+// New returns an empty Composer
+func New() *Composer {
+	return &Composer{}
+}
+
+// Part appends an arbitrary part
+func (c *Composer) Part(p Part) *Composer {
+	c.parts = append(c.parts, p)
+	return c
+}
+
+// Merge appends the parts of other
+func (c *Composer) Merge(other *Composer) *Composer {
+	c.parts = append(c.parts, other.parts...)
+	return c
+}
+
+// CloudConfig appends a cloud-config YAML document. The "#cloud-config"
+// header is added when missing.
+func (c *Composer) CloudConfig(config string) *Composer {
+	if !strings.HasPrefix(config, cloudConfigHeader) {
+		config = cloudConfigHeader + "\n" + config
+	}
+	return c.Part(Part{ContentType: ContentTypeCloudConfig, Filename: "cloud-config.yaml", Content: config})
+}
+
+// ShellScript appends a script that cloud-init runs once on first boot. A
+// "#!/bin/sh" interpreter line is added when missing.
+func (c *Composer) ShellScript(filename, script string) *Composer {
+	if !strings.HasPrefix(script, "#!") {
+		script = "#!/bin/sh\n" + script
+	}
+	return c.Part(Part{ContentType: ContentTypeShellScript, Filename: filename, Content: script})
+}
+
+// IncludeURL appends a list of URLs whose content cloud-init downloads and
+// processes as additional user data
+func (c *Composer) IncludeURL(urls ...string) *Composer {
+	return c.Part(Part{ContentType: ContentTypeIncludeURL, Filename: "include.txt", Content: strings.Join(urls, "\n") + "\n"})
+}
+
+// PhoneHome appends a script that reports to the Equinix Metal metadata
+// service that the device has booted. This is needed for custom_ipxe
+// installs to leave the provisioning state.
+func (c *Composer) PhoneHome() *Composer {
+	script := fmt.Sprintf("#!/bin/sh\ncurl -fsS -X POST -d '' %s/phone-home\n", metadata.BaseURL)
+	return c.Part(Part{ContentType: ContentTypeShellScript, Filename: "phone-home.sh", Content: script})
+}
+
+// MetadataNetwork appends a script that adds the non-management addresses
+// listed in the device metadata to iface, e.g. "bond0". The script requires
+// curl and jq on the device.
+func (c *Composer) MetadataNetwork(iface string) *Composer {
+	script := fmt.Sprintf(`#!/bin/sh
+set -eu
+curl -fsS %s/metadata |
+	jq -r '.network.addresses[] | select(.management == false) | "\(.address)/\(.cidr)"' |
+	while read -r addr; do
+		ip addr add "$addr" dev %s || true
+	done
+`, metadata.BaseURL, iface)
+	return c.Part(Part{ContentType: ContentTypeShellScript, Filename: "metadata-network.sh", Content: script})
+}
+
+// Gzip compresses the document returned by Bytes. cloud-init detects and
+// decompresses gzipped user data, but the API transports user data as a JSON
+// string that can not carry the compressed bytes, so gzipped documents are for
+// channels serving raw bytes, such as a user data HTTP endpoint. Render,
+// ApplyToCreate and ApplyToUpdate can not be used with Gzip and fail.
+func (c *Composer) Gzip(enabled bool) *Composer {
+	c.gzip = enabled
+	return c
+}
+
+// Validate checks each part for problems that cloud-init would silently
+// ignore, such as unparsable cloud-config YAML or malformed include URLs
+func (c *Composer) Validate() error {
+	if len(c.parts) == 0 {
+		return fmt.Errorf("user data has no parts")
+	}
+	for i, p := range c.parts {
+		if p.ContentType == "" {
+			return fmt.Errorf("part %d (%s) has no content type", i, p.Filename)
+		}
+		if strings.Contains(p.Content, Boundary) {
+			return fmt.Errorf("part %d (%s) contains the MIME boundary %q", i, p.Filename, Boundary)
+		}
+		switch p.ContentType {
+		case ContentTypeCloudConfig:
+			var doc map[string]interface{}
+			if err := yaml.Unmarshal([]byte(p.Content), &doc); err != nil {
+				return fmt.Errorf("part %d (%s) is not valid cloud-config: %w", i, p.Filename, err)
+			}
+		case ContentTypeIncludeURL:
+			for _, line := range strings.Split(strings.TrimSpace(p.Content), "\n") {
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				if u, err := url.Parse(line); err != nil || u.Scheme == "" || u.Host == "" {
+					return fmt.Errorf("part %d (%s) has an invalid include URL %q", i, p.Filename, line)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Bytes validates and renders the MIME multipart document, gzipped if
+// requested
+func (c *Composer) Bytes() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	if err := w.SetBoundary(Boundary); err != nil {
+		return nil, err
+	}
+	for _, p := range c.parts {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", fmt.Sprintf("%s; charset=\"utf-8\"", p.ContentType))
+		h.Set("MIME-Version", "1.0")
+		h.Set("Content-Transfer-Encoding", "7bit")
+		if p.Filename != "" {
+			h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", p.Filename))
+		}
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pw.Write([]byte(p.Content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	doc := new(bytes.Buffer)
+	fmt.Fprintf(doc, "Content-Type: multipart/mixed; boundary=%q\r\nMIME-Version: 1.0\r\n\r\n", Boundary)
+	doc.Write(body.Bytes())
+
+	if !c.gzip {
+		return doc.Bytes(), nil
+	}
+
+	gz := new(bytes.Buffer)
+	zw := gzip.NewWriter(gz)
+	if _, err := zw.Write(doc.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return gz.Bytes(), nil
+}
+
+// Render renders the user data for the API. It fails if Gzip is enabled or
+// the result exceeds packngo.DeviceUserDataMaxSize.
+func (c *Composer) Render() (string, error) {
+	if c.gzip {
+		return "", fmt.Errorf("gzipped user data can not be sent through the API, use Bytes or disable Gzip")
+	}
+	b, err := c.Bytes()
+	if err != nil {
+		return "", err
+	}
+	if len(b) > packngo.DeviceUserDataMaxSize {
+		return "", fmt.Errorf("user data is %d bytes, exceeding the %d byte limit", len(b), packngo.DeviceUserDataMaxSize)
+	}
+	return string(b), nil
+}
+
+// ApplyToCreate sets the UserData of a DeviceCreateRequest
+func (c *Composer) ApplyToCreate(req *packngo.DeviceCreateRequest) error {
+	s, err := c.Render()
+	if err != nil {
+		return err
+	}
+	req.UserData = s
+	return nil
+}
+
+// ApplyToUpdate sets the UserData of a DeviceUpdateRequest
+func (c *Composer) ApplyToUpdate(req *packngo.DeviceUpdateRequest) error {
+	s, err := c.Render()
+	if err != nil {
+		return err
+	}
+	req.UserData = &s
+	return nil
+}
@@ -0,0 +1,117 @@
+package userdata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/packethost/packngo"
+	"github.com/stretchr/testify/assert"
+)
+
+type part struct {
+	header   textproto.MIMEHeader
+	filename string
+	content  string
+}
+
+func parseParts(t *testing.T, doc []byte) []part {
+	msg, err := mail.ReadMessage(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	var parts []part
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		b, err := ioutil.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part{header: p.Header, filename: p.FileName(), content: string(b)})
+	}
+	return parts
+}
+
+func Test_Compose(t *testing.T) {
+	doc, err := New().
+		CloudConfig("packages: [nginx]").
+		ShellScript("setup.sh", "systemctl enable --now nginx").
+		IncludeURL("https://example.com/extra.yaml").
+		PhoneHome().
+		Bytes()
+	assert.Nil(t, err)
+
+	parts := parseParts(t, doc)
+	assert.Len(t, parts, 4)
+
+	assert.Equal(t, `text/cloud-config; charset="utf-8"`, parts[0].header.Get("Content-Type"))
+	assert.Equal(t, "#cloud-config\npackages: [nginx]", parts[0].content)
+
+	assert.Equal(t, "setup.sh", parts[1].filename)
+	assert.Equal(t, "#!/bin/sh\nsystemctl enable --now nginx", parts[1].content)
+
+	assert.Equal(t, "https://example.com/extra.yaml\n", parts[2].content)
+	assert.Contains(t, parts[3].content, "/phone-home")
+}
+
+func Test_Gzip(t *testing.T) {
+	c := New().CloudConfig("packages: [nginx]").Gzip(true)
+	b, err := c.Bytes()
+	assert.Nil(t, err)
+
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	assert.Nil(t, err)
+	doc, err := ioutil.ReadAll(zr)
+	assert.Nil(t, err)
+	assert.Len(t, parseParts(t, doc), 1)
+
+	_, err = c.Render()
+	assert.Error(t, err)
+	assert.Error(t, c.ApplyToCreate(&packngo.DeviceCreateRequest{}))
+	assert.Error(t, c.ApplyToUpdate(&packngo.DeviceUpdateRequest{}))
+}
+
+func Test_Validate(t *testing.T) {
+	_, err := New().Render()
+	assert.Error(t, err)
+
+	_, err = New().CloudConfig("packages: [nginx").Render()
+	assert.Error(t, err)
+
+	_, err = New().IncludeURL("not a url").Render()
+	assert.Error(t, err)
+
+	_, err = New().ShellScript("big.sh", strings.Repeat("#", packngo.DeviceUserDataMaxSize)).Render()
+	assert.Error(t, err)
+
+	_, err = New().ShellScript("bad.sh", "echo "+Boundary).Render()
+	assert.Error(t, err)
+}
+
+func Test_Apply(t *testing.T) {
+	c := New().Merge(New().MetadataNetwork("bond0")).CloudConfig("runcmd: [date]")
+
+	create := &packngo.DeviceCreateRequest{}
+	assert.Nil(t, c.ApplyToCreate(create))
+	assert.Contains(t, create.UserData, "dev bond0")
+
+	update := &packngo.DeviceUpdateRequest{}
+	assert.Nil(t, c.ApplyToUpdate(update))
+	assert.Equal(t, create.UserData, *update.UserData)
+}
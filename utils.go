@@ -2,6 +2,7 @@ package packngo
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
@@ -9,7 +10,8 @@ import (
 )
 
 var (
-	timestampType = reflect.TypeOf(Timestamp{})
+	timestampType   = reflect.TypeOf(Timestamp{})
+	extraFieldsType = reflect.TypeOf(ExtraFields{})
 
 	// Facilities DEPRECATED Use Facilities.List
 	Facilities = []string{
@@ -71,6 +73,19 @@ func stringifyValue(w io.Writer, val reflect.Value) error {
 
 	v := reflect.Indirect(val)
 
+	// ExtraFields are written as JSON rather than as byte slices
+	if v.Type() == extraFieldsType {
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		if redactionEnabled() {
+			b = redactJSON(b)
+		}
+		_, err = w.Write(b)
+		return err
+	}
+
 	switch v.Kind() {
 	case reflect.String:
 		if _, err := fmt.Fprintf(w, `"%s"`, v); err != nil {
@@ -122,6 +137,9 @@ func stringifyValue(w io.Writer, val reflect.Value) error {
 			if fv.Kind() == reflect.Slice && fv.IsNil() {
 				continue
 			}
+			if fv.Kind() == reflect.Map && fv.IsNil() {
+				continue
+			}
 
 			if sep {
 				if _, err := w.Write([]byte(", ")); err != nil {
@@ -138,6 +156,13 @@ func stringifyValue(w io.Writer, val reflect.Value) error {
 				return err
 			}
 
+			if !fv.IsZero() && redactionEnabled() && redactStructField(v.Type().Field(i)) {
+				if _, err := fmt.Fprintf(w, `"%s"`, redactedValue); err != nil {
+					return err
+				}
+				continue
+			}
+
 			if err := stringifyValue(w, fv); err != nil {
 				return err
 			}
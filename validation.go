@@ -0,0 +1,61 @@
+package packngo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// hostnameLabelRE matches a single RFC 1123 hostname label
+var hostnameLabelRE = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// ValidationError describes a request field that failed client-side validation
+type ValidationError struct {
+	// Field is the JSON name of the offending field
+	Field string
+
+	// Reason describes why the field is invalid
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// ValidationErrors collects every ValidationError found in a request
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid request: %s", strings.Join(msgs, "; "))
+}
+
+// add records a ValidationError for field
+func (e *ValidationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, &ValidationError{Field: field, Reason: fmt.Sprintf(format, args...)})
+}
+
+// errOrNil returns nil when no ValidationErrors were recorded. This avoids
+// returning a non-nil error interface holding an empty slice.
+func (e ValidationErrors) errOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ValidateHostname checks that hostname is a valid RFC 1123 hostname
+func ValidateHostname(hostname string) error {
+	if len(hostname) == 0 || len(hostname) > 253 {
+		return fmt.Errorf("%q must be between 1 and 253 characters", hostname)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(hostname, "."), ".") {
+		if !hostnameLabelRE.MatchString(label) {
+			return fmt.Errorf("%q is not a valid RFC 1123 hostname, label %q must be 1-63 alphanumeric characters or hyphens and must not start or end with a hyphen", hostname, label)
+		}
+	}
+	return nil
+}
@@ -125,6 +125,9 @@ type VirtualCircuit struct {
 
 	// MD5 (returned with VRF) The password that can be set for the VRF BGP peer
 	MD5 string `json:"md5,omitempty"`
+
+	// Extra holds the fields returned by the API that are not modeled above
+	Extra ExtraFields `json:"-"`
 }
 
 func (s *VirtualCircuitServiceOp) do(method, apiPathQuery string, req interface{}) (*VirtualCircuit, *Response, error) {
@@ -1,12 +1,23 @@
 package packngo
 
 import (
+	"fmt"
 	"path"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	portVLANAssignmentsPath      = "vlan-assignments"
 	portVLANAssignmentsBatchPath = "batches"
+
+	// VLANAssignmentBatchTimeout is the default time Sync waits for a batch
+	// to reach a terminal state
+	VLANAssignmentBatchTimeout = 5 * time.Minute
+
+	// VLANAssignmentBatchCheck is the default interval between batch state checks
+	VLANAssignmentBatchCheck = 2 * time.Second
 )
 
 type vlanAssignmentsRoot struct {
@@ -115,6 +126,273 @@ type VLANAssignmentCreateRequest struct {
 	Native *bool               `json:"native,omitempty"`
 }
 
+// VLANAssignmentSyncRequest describes the complete set of VLANs that should be
+// assigned to a port. VLANs and Native accept either the VirtualNetwork.ID or
+// the VirtualNetwork.VXLAN (as a string) of each VLAN.
+type VLANAssignmentSyncRequest struct {
+	// VLANs that should be assigned to the port. Assigned VLANs that are not
+	// listed will be unassigned.
+	VLANs []string
+
+	// Native is the VLAN that should be native on the port. Native is
+	// implicitly included in VLANs. When empty, no VLAN will be native.
+	Native string
+
+	// Timeout limits how long Sync waits for the batch to complete. Defaults
+	// to VLANAssignmentBatchTimeout.
+	Timeout time.Duration
+
+	// PollInterval is the delay between batch state checks. Defaults to
+	// VLANAssignmentBatchCheck.
+	PollInterval time.Duration
+}
+
+// VLANAssignmentBatchError is returned when a VLANAssignmentBatch fails or
+// does not complete in time
+type VLANAssignmentBatchError struct {
+	PortID  string
+	BatchID string
+	State   VLANAssignmentBatchState
+
+	// ErrorMessages are the VLANAssignmentBatch.ErrorMessages reported by the API
+	ErrorMessages []string
+}
+
+func (e *VLANAssignmentBatchError) Error() string {
+	if e.State != VLANAssignmentBatchFailed {
+		return fmt.Sprintf("vlan assignment batch %s on port %s is still %s after timeout", e.BatchID, e.PortID, e.State)
+	}
+	return fmt.Sprintf("vlan assignment batch %s on port %s failed: %s", e.BatchID, e.PortID, strings.Join(e.ErrorMessages, "; "))
+}
+
+// matches reports whether the VLAN reference (VirtualNetwork.ID or VXLAN)
+// identifies the VLAN of the assignment
+func (a *VLANAssignment) matches(vlan string) bool {
+	if a.VirtualNetwork != nil && a.VirtualNetwork.ID != "" && a.VirtualNetwork.ID == vlan {
+		return true
+	}
+	return a.VLAN != 0 && strconv.Itoa(a.VLAN) == vlan
+}
+
+// DiffVLANAssignments returns the minimal VLANAssignmentBatchCreateRequest
+// needed to move a port from the current assignments to the desired VLANs and
+// native VLAN. Unassignments and native VLAN removals are ordered before
+// assignments. A nil request is returned when no changes are needed.
+//
+// networks are the VirtualNetworks referenced by vlans and native. They let
+// an ID and a VXLAN referencing the same VLAN be recognized as one VLAN
+// before it is assigned, so that it is not assigned twice.
+func DiffVLANAssignments(current []VLANAssignment, vlans []string, native string, networks ...VirtualNetwork) *VLANAssignmentBatchCreateRequest {
+	known := append([]VLANAssignment{}, current...)
+	for i := range networks {
+		known = append(known, VLANAssignment{VLAN: networks[i].VXLAN, VirtualNetwork: &networks[i]})
+	}
+	sameVLAN := func(a, b string) bool {
+		if a == b {
+			return true
+		}
+		for i := range known {
+			if known[i].matches(a) && known[i].matches(b) {
+				return true
+			}
+		}
+		return false
+	}
+
+	desired := []string{}
+	for _, v := range append(append([]string{}, vlans...), native) {
+		if v == "" {
+			continue
+		}
+		duplicate := false
+		for _, d := range desired {
+			duplicate = duplicate || sameVLAN(d, v)
+		}
+		if !duplicate {
+			desired = append(desired, v)
+		}
+	}
+
+	isDesired := func(a *VLANAssignment) bool {
+		for _, v := range desired {
+			if a.matches(v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	nativeTrue, nativeFalse := true, false
+	var removals, additions []VLANAssignmentCreateRequest
+	assigned := []VLANAssignment{}
+	for _, a := range current {
+		if a.State != VLANAssignmentAssigned {
+			continue
+		}
+		assigned = append(assigned, a)
+		ref := vlanAssignmentRef(a)
+		switch {
+		case !isDesired(&a):
+			removals = append(removals, VLANAssignmentCreateRequest{VLAN: ref, State: VLANAssignmentUnassigned})
+		case a.Native && (native == "" || !a.matches(native)):
+			removals = append(removals, VLANAssignmentCreateRequest{VLAN: ref, State: VLANAssignmentAssigned, Native: &nativeFalse})
+		}
+	}
+
+	for _, v := range desired {
+		var found *VLANAssignment
+		for i := range assigned {
+			if assigned[i].matches(v) {
+				found = &assigned[i]
+				break
+			}
+		}
+		isNative := native != "" && sameVLAN(v, native)
+		switch {
+		case found == nil && isNative:
+			additions = append(additions, VLANAssignmentCreateRequest{VLAN: v, State: VLANAssignmentAssigned, Native: &nativeTrue})
+		case found == nil:
+			additions = append(additions, VLANAssignmentCreateRequest{VLAN: v, State: VLANAssignmentAssigned})
+		case isNative && !found.Native:
+			additions = append(additions, VLANAssignmentCreateRequest{VLAN: v, State: VLANAssignmentAssigned, Native: &nativeTrue})
+		}
+	}
+
+	if len(removals)+len(additions) == 0 {
+		return nil
+	}
+	return &VLANAssignmentBatchCreateRequest{VLANAssignments: append(removals, additions...)}
+}
+
+// vlanAssignmentRef returns the VirtualNetwork.ID of an assignment, falling
+// back to the VXLAN when the VirtualNetwork was not included
+func vlanAssignmentRef(a VLANAssignment) string {
+	if a.VirtualNetwork != nil && a.VirtualNetwork.ID != "" {
+		return a.VirtualNetwork.ID
+	}
+	return strconv.Itoa(a.VLAN)
+}
+
+// VLANAssignmentSyncer assigns and unassigns the VLANs of ports to match a
+// desired state
+type VLANAssignmentSyncer struct {
+	Assignments VLANAssignmentService
+
+	// VirtualNetworks resolves VLANs referenced by ID that are not assigned
+	// yet. When nil, VLANs are only resolved from the current assignments.
+	VirtualNetworks ProjectVirtualNetworkService
+}
+
+// NewVLANAssignmentSyncer uses the services of c
+func NewVLANAssignmentSyncer(c *Client) *VLANAssignmentSyncer {
+	return &VLANAssignmentSyncer{Assignments: c.VLANAssignments, VirtualNetworks: c.ProjectVirtualNetworks}
+}
+
+// Sync assigns and unassigns VLANs on a port so that the port carries exactly
+// the requested VLANs and native VLAN. The current assignments are read with
+// List, the minimal batch is submitted with CreateBatch, and Sync waits until
+// the batch is completed or failed. A failed batch is reported as a
+// *VLANAssignmentBatchError. When the port already matches the request, no
+// batch is created and a nil batch is returned.
+func (s *VLANAssignmentSyncer) Sync(portID string, request *VLANAssignmentSyncRequest) (*VLANAssignmentBatch, *Response, error) {
+	if validateErr := ValidateUUID(portID); validateErr != nil {
+		return nil, nil, validateErr
+	}
+	if request == nil {
+		request = &VLANAssignmentSyncRequest{}
+	}
+
+	opts := &ListOptions{Includes: []string{"virtual_network"}}
+	current, resp, err := s.Assignments.List(portID, opts)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	networks, resp, err := s.resolveVLANs(current, append(append([]string{}, request.VLANs...), request.Native))
+	if err != nil {
+		return nil, resp, err
+	}
+
+	batchRequest := DiffVLANAssignments(current, request.VLANs, request.Native, networks...)
+	if batchRequest == nil {
+		return nil, resp, nil
+	}
+
+	batch, resp, err := s.Assignments.CreateBatch(portID, batchRequest, nil)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return pollVLANAssignmentBatch(s.Assignments, portID, batch, request.Timeout, request.PollInterval)
+}
+
+// resolveVLANs fetches the VirtualNetworks referenced by ID that are not
+// among the current assignments
+func (s *VLANAssignmentSyncer) resolveVLANs(current []VLANAssignment, refs []string) (networks []VirtualNetwork, resp *Response, err error) {
+	if s.VirtualNetworks == nil {
+		return nil, nil, nil
+	}
+	for _, ref := range refs {
+		if ValidateUUID(ref) != nil {
+			continue
+		}
+		resolved := false
+		for i := range current {
+			resolved = resolved || current[i].matches(ref)
+		}
+		for i := range networks {
+			resolved = resolved || networks[i].ID == ref
+		}
+		if resolved {
+			continue
+		}
+
+		vn, r, err := s.VirtualNetworks.Get(ref, nil)
+		if err != nil {
+			return nil, r, err
+		}
+		resp = r
+		networks = append(networks, *vn)
+	}
+	return networks, resp, nil
+}
+
+// pollVLANAssignmentBatch polls a VLANAssignmentBatch until it is completed
+// or failed
+func pollVLANAssignmentBatch(s VLANAssignmentService, portID string, batch *VLANAssignmentBatch, timeout, interval time.Duration) (*VLANAssignmentBatch, *Response, error) {
+	if timeout == 0 {
+		timeout = VLANAssignmentBatchTimeout
+	}
+	if interval == 0 {
+		interval = VLANAssignmentBatchCheck
+	}
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var resp *Response
+	for {
+		switch batch.State {
+		case VLANAssignmentBatchCompleted:
+			return batch, resp, nil
+		case VLANAssignmentBatchFailed:
+			return batch, resp, &VLANAssignmentBatchError{PortID: portID, BatchID: batch.ID, State: batch.State, ErrorMessages: batch.ErrorMessages}
+		}
+
+		select {
+		case <-ticker.C:
+			b, r, err := s.GetBatch(portID, batch.ID, nil)
+			if err != nil {
+				return batch, r, err
+			}
+			batch, resp = b, r
+		case <-deadline:
+			return batch, resp, &VLANAssignmentBatchError{PortID: portID, BatchID: batch.ID, State: batch.State, ErrorMessages: batch.ErrorMessages}
+		}
+	}
+}
+
 // List returns VLANAssignmentBatches
 func (s *VLANAssignmentServiceOp) ListBatch(portID string, opts *ListOptions) (results []VLANAssignmentBatch, resp *Response, err error) {
 	if validateErr := ValidateUUID(portID); validateErr != nil {
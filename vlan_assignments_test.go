@@ -262,3 +262,185 @@ func waitVLANAssignmentBatch(t *testing.T, c *Client, portID, id string) *VLANAs
 	t.Fatal(fmt.Errorf("vlan assignment batch %s is still not complete after timeout", id))
 	return nil
 }
+
+func TestDiffVLANAssignments(t *testing.T) {
+	bTrue, bFalse := true, false
+	vn := func(id string) *VirtualNetwork { return &VirtualNetwork{ID: id} }
+	current := []VLANAssignment{
+		{VLAN: 1000, VirtualNetwork: vn("vn-1000"), State: VLANAssignmentAssigned, Native: true},
+		{VLAN: 1001, VirtualNetwork: vn("vn-1001"), State: VLANAssignmentAssigned},
+		{VLAN: 1002, State: VLANAssignmentUnassigned},
+	}
+
+	tests := []struct {
+		name     string
+		vlans    []string
+		native   string
+		networks []VirtualNetwork
+		want     *VLANAssignmentBatchCreateRequest
+	}{
+		{
+			name:   "NoChange",
+			vlans:  []string{"vn-1000", "1001"},
+			native: "1000",
+			want:   nil,
+		},
+		{
+			name:   "AssignAndUnassign",
+			vlans:  []string{"1000", "1003"},
+			native: "vn-1000",
+			want: &VLANAssignmentBatchCreateRequest{VLANAssignments: []VLANAssignmentCreateRequest{
+				{VLAN: "vn-1001", State: VLANAssignmentUnassigned},
+				{VLAN: "1003", State: VLANAssignmentAssigned},
+			}},
+		},
+		{
+			name:   "MoveNative",
+			vlans:  []string{"1000"},
+			native: "1001",
+			want: &VLANAssignmentBatchCreateRequest{VLANAssignments: []VLANAssignmentCreateRequest{
+				{VLAN: "vn-1000", State: VLANAssignmentAssigned, Native: &bFalse},
+				{VLAN: "1001", State: VLANAssignmentAssigned, Native: &bTrue},
+			}},
+		},
+		{
+			name:     "MixedReferences",
+			vlans:    []string{"1000", "1001", "vn-2000", "2001"},
+			native:   "2000",
+			networks: []VirtualNetwork{{ID: "vn-2000", VXLAN: 2000}, {ID: "vn-2001", VXLAN: 2001}},
+			want: &VLANAssignmentBatchCreateRequest{VLANAssignments: []VLANAssignmentCreateRequest{
+				{VLAN: "vn-1000", State: VLANAssignmentAssigned, Native: &bFalse},
+				{VLAN: "vn-2000", State: VLANAssignmentAssigned, Native: &bTrue},
+				{VLAN: "2001", State: VLANAssignmentAssigned},
+			}},
+		},
+		{
+			name: "UnassignAll",
+			want: &VLANAssignmentBatchCreateRequest{VLANAssignments: []VLANAssignmentCreateRequest{
+				{VLAN: "vn-1000", State: VLANAssignmentUnassigned},
+				{VLAN: "vn-1001", State: VLANAssignmentUnassigned},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffVLANAssignments(current, tt.vlans, tt.native, tt.networks...)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("DiffVLANAssignments() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVLANAssignmentSyncer_Sync(t *testing.T) {
+	const testBatchId = "6a8d0e6d-7e54-4a3c-a1f2-3cc9a0fd9d1b"
+	listPath := path.Join(portBasePath, testPortId, portVLANAssignmentsPath)
+	batchPath := path.Join(listPath, portVLANAssignmentsBatchPath)
+
+	tests := []struct {
+		name      string
+		states    []VLANAssignmentBatchState
+		wantState VLANAssignmentBatchState
+		wantErr   bool
+	}{
+		{name: "Completed", states: []VLANAssignmentBatchState{VLANAssignmentBatchQueued, VLANAssignmentBatchInProgress, VLANAssignmentBatchCompleted}, wantState: VLANAssignmentBatchCompleted},
+		{name: "Failed", states: []VLANAssignmentBatchState{VLANAssignmentBatchQueued, VLANAssignmentBatchFailed}, wantState: VLANAssignmentBatchFailed, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polls := 0
+			var created *VLANAssignmentBatchCreateRequest
+			s := &VLANAssignmentSyncer{Assignments: &VLANAssignmentServiceOp{client: &MockClient{
+				fnDoRequest: func(method, pathURL string, body, v interface{}) (*Response, error) {
+					switch {
+					case method == "GET" && strings.HasPrefix(pathURL, listPath+"?"):
+						v.(*vlanAssignmentsRoot).VLANAssignments = []VLANAssignment{{VLAN: 1234, State: VLANAssignmentAssigned}}
+					case method == "POST" && pathURL == batchPath:
+						created = body.(*VLANAssignmentBatchCreateRequest)
+						*v.(*VLANAssignmentBatch) = VLANAssignmentBatch{ID: testBatchId, State: tt.states[0]}
+					case method == "GET" && pathURL == path.Join(batchPath, testBatchId):
+						polls++
+						b := v.(*VLANAssignmentBatch)
+						*b = VLANAssignmentBatch{ID: testBatchId, State: tt.states[polls]}
+						if b.State == VLANAssignmentBatchFailed {
+							b.ErrorMessages = []string{"vlan 1235 not found"}
+						}
+					default:
+						return nil, fmt.Errorf("unexpected %s %s", method, pathURL)
+					}
+					return mockResponse(200, "", nil), nil
+				},
+			}}}
+
+			b, _, err := s.Sync(testPortId, &VLANAssignmentSyncRequest{VLANs: []string{"1235"}, PollInterval: time.Millisecond})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VLANAssignmentSyncer.Sync() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if b.State != tt.wantState {
+				t.Errorf("VLANAssignmentSyncer.Sync() state = %s, want %s", b.State, tt.wantState)
+			}
+			if tt.wantErr {
+				batchErr, ok := err.(*VLANAssignmentBatchError)
+				if !ok || len(batchErr.ErrorMessages) != 1 {
+					t.Errorf("VLANAssignmentSyncer.Sync() error = %#v, want *VLANAssignmentBatchError with messages", err)
+				}
+			}
+			want := []VLANAssignmentCreateRequest{
+				{VLAN: "1234", State: VLANAssignmentUnassigned},
+				{VLAN: "1235", State: VLANAssignmentAssigned},
+			}
+			if diff := cmp.Diff(want, created.VLANAssignments); diff != "" {
+				t.Errorf("VLANAssignmentSyncer.Sync() batch mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+type fakeSyncVirtualNetworks struct {
+	ProjectVirtualNetworkService
+	networks map[string]VirtualNetwork
+	gets     int
+}
+
+func (f *fakeSyncVirtualNetworks) Get(id string, _ *GetOptions) (*VirtualNetwork, *Response, error) {
+	f.gets++
+	vn, ok := f.networks[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("virtual network %s not found", id)
+	}
+	return &vn, nil, nil
+}
+
+func TestVLANAssignmentSyncer_SyncResolvesIDs(t *testing.T) {
+	batchPath := path.Join(portBasePath, testPortId, portVLANAssignmentsPath, portVLANAssignmentsBatchPath)
+	var created *VLANAssignmentBatchCreateRequest
+	networks := &fakeSyncVirtualNetworks{networks: map[string]VirtualNetwork{testVnId: {ID: testVnId, VXLAN: 1000}}}
+	s := &VLANAssignmentSyncer{
+		Assignments: &VLANAssignmentServiceOp{client: &MockClient{
+			fnDoRequest: func(method, pathURL string, body, v interface{}) (*Response, error) {
+				switch {
+				case method == "GET":
+				case method == "POST" && pathURL == batchPath:
+					created = body.(*VLANAssignmentBatchCreateRequest)
+					*v.(*VLANAssignmentBatch) = VLANAssignmentBatch{State: VLANAssignmentBatchCompleted}
+				default:
+					return nil, fmt.Errorf("unexpected %s %s", method, pathURL)
+				}
+				return mockResponse(200, "", nil), nil
+			},
+		}},
+		VirtualNetworks: networks,
+	}
+
+	if _, _, err := s.Sync(testPortId, &VLANAssignmentSyncRequest{VLANs: []string{testVnId}, Native: "1000"}); err != nil {
+		t.Fatal(err)
+	}
+	bTrue := true
+	want := []VLANAssignmentCreateRequest{{VLAN: testVnId, State: VLANAssignmentAssigned, Native: &bTrue}}
+	if diff := cmp.Diff(want, created.VLANAssignments); diff != "" {
+		t.Errorf("VLANAssignmentSyncer.Sync() batch mismatch (-want +got):\n%s", diff)
+	}
+	if networks.gets != 1 {
+		t.Errorf("VLANAssignmentSyncer.Sync() fetched %d virtual networks, want 1", networks.gets)
+	}
+}